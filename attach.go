@@ -0,0 +1,101 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// AttachMode selects whether Start attaches to a Postgres server that is already running on the DataPath instead of
+// starting a new one, and what Stop then does with it.
+type AttachMode string
+
+// Attach modes supported by Start.
+const (
+	// AttachAndStop attaches to a running server and stops it on Stop, as if Start had started it.
+	AttachAndStop = AttachMode("stop")
+	// AttachAndDetach attaches to a running server and leaves it running on Stop.
+	AttachAndDetach = AttachMode("detach")
+)
+
+// attach takes over a Postgres server that is already running on the data directory, reporting false when there is
+// none so that Start goes on to start a new one.
+func (ep *EmbeddedPostgres) attach(ctx context.Context) (bool, error) {
+	pidFile, exists, err := readPostmasterPidFile(ep.config.dataPath)
+	if err != nil || !exists {
+		return false, err
+	}
+
+	running, err := pgCtlStatus(ctx, ep)
+	if err != nil || !running {
+		return false, err
+	}
+
+	config := ep.config
+	config.port = pidFile.port
+
+	// the server only listens on its socket directory, or a private one chosen by the process that started it
+	if pidFile.socketDir != "" && (pidFile.listenAddress == "" || (config.unixSocket && config.unixSocketDirectory == "")) {
+		config.unixSocket = true
+		config.unixSocketDirectory = pidFile.socketDir
+	}
+
+	if err := verifyCredentials(ctx, config); err != nil {
+		return false, &AttachError{DataPath: config.dataPath, Port: config.port, Err: err}
+	}
+
+	ep.stateMu.Lock()
+	ep.config = config
+	ep.postmaster = nil
+	ep.done = make(chan struct{})
+	ep.attached = true
+	ep.stateMu.Unlock()
+
+	ep.dataReused = true
+
+	return true, nil
+}
+
+// pgCtlStatus reports whether pg_ctl finds a server running on the data directory. It reports false when the binaries
+// have not been extracted yet, as no server can have been started from them.
+func pgCtlStatus(ctx context.Context, ep *EmbeddedPostgres) (bool, error) {
+	postgresBinary := filepath.Join(ep.config.binariesPath, "bin/pg_ctl")
+	if _, err := os.Stat(postgresBinary); err != nil {
+		return false, nil
+	}
+
+	postgresProcess := exec.CommandContext(ctx, postgresBinary, "status", "-D", ep.config.dataPath)
+	postgresProcess.Stdout = ep.syncedLogger.file
+	postgresProcess.Stderr = ep.syncedLogger.file
+	ep.runAs.apply(postgresProcess)
+
+	err := postgresProcess.Run()
+	if err == nil {
+		return true, nil
+	}
+
+	// pg_ctl exits with 3 when no server is running and 4 when the data directory is not accessible
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && (exitErr.ExitCode() == 3 || exitErr.ExitCode() == 4) {
+		return false, nil
+	}
+
+	return false, err
+}
+
+func verifyCredentials(ctx context.Context, config Config) (err error) {
+	conn, err := openDatabaseConnection(config.connectionHost(), config.port, config.username, config.password, config.database)
+	if err != nil {
+		return err
+	}
+
+	db := sql.OpenDB(conn)
+	defer func() {
+		err = connectionClose(db, err)
+	}()
+
+	return db.PingContext(ctx)
+}
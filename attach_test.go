@@ -0,0 +1,223 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"database/sql"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_attach_NoPidFile(t *testing.T) {
+	database, _ := fixtureDatabase(t, DefaultConfig(), nil)
+
+	attached, err := database.attach(context.Background())
+
+	assert.NoError(t, err)
+	assert.False(t, attached)
+}
+
+func Test_attach_NotRunningAccordingToPgCtl(t *testing.T) {
+	database, _ := attachTestDatabase(t, "3")
+
+	attached, err := database.attach(context.Background())
+
+	assert.NoError(t, err)
+	assert.False(t, attached)
+}
+
+func Test_attach_ErrorWhenCredentialsCannotBeVerified(t *testing.T) {
+	database, port := attachTestDatabase(t, "0")
+
+	attached, err := database.attach(context.Background())
+
+	assert.False(t, attached)
+	assert.ErrorIs(t, err, ErrAttachFailed)
+	assert.Contains(t, err.Error(), "unable to attach to postgres running on data directory "+database.config.dataPath)
+
+	var attachErr *AttachError
+	require.ErrorAs(t, err, &attachErr)
+	assert.Equal(t, port, attachErr.Port)
+	assert.Equal(t, uint32(5432), database.config.port)
+}
+
+func Test_Attach_SkipsStartSteps(t *testing.T) {
+	var calls []string
+
+	database, _ := fixtureDatabase(t, DefaultConfig().
+		UnixSocketDirectory(t.TempDir()).
+		TCP(false).
+		Port(9854).
+		Attach(AttachAndDetach).
+		Databases(DatabaseSpec{Name: "orders"}).
+		Roles(RoleSpec{Name: "app", Password: "app", Login: true}).
+		AfterStart(recordingHook(&calls, "AfterStart", nil)), map[string]string{"pg_ctl": exitScript("0")})
+
+	socketDirectory := database.config.unixSocketDirectory
+	fakePostgresServer(t, socketDirectory, 9854)
+
+	content := "1\n" + database.config.dataPath + "\n0\n9854\n" + socketDirectory + "\n\n"
+	require.NoError(t, os.WriteFile(filepath.Join(database.config.dataPath, "postmaster.pid"), []byte(content), 0600))
+
+	require.NoError(t, database.Start())
+
+	assert.True(t, database.attached)
+	assert.Empty(t, calls)
+	assert.Zero(t, database.StartResult().Duration(PhaseCreateDatabase))
+
+	require.NoError(t, database.Stop())
+}
+
+func attachTestDatabase(t *testing.T, pgCtlExitCode string) (*EmbeddedPostgres, uint32) {
+	database, _ := fixtureDatabase(t, DefaultConfig(), map[string]string{"pg_ctl": exitScript(pgCtlExitCode)})
+
+	// a port that nothing listens on, as recorded by a server that has since stopped accepting connections
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+
+	port := listener.Addr().(*net.TCPAddr).Port
+	require.NoError(t, listener.Close())
+
+	content := "1\n" + database.config.dataPath + "\n0\n" + strconv.Itoa(port) + "\n\nlocalhost\n"
+	require.NoError(t, os.WriteFile(filepath.Join(database.config.dataPath, "postmaster.pid"), []byte(content), 0600))
+
+	return database, uint32(port)
+}
+
+func Test_Attach_DetachLeavesServerRunning(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "embedded_postgres_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	config := DefaultConfig().
+		Port(9841).
+		RuntimePath(filepath.Join(tempDir, "runtime")).
+		DataPath(filepath.Join(tempDir, "data"))
+
+	original := NewDatabase(config)
+	if err := original.Start(); err != nil {
+		shutdownDBAndFail(t, err, original)
+	}
+
+	attached := NewDatabase(config.
+		Port(0).
+		Attach(AttachAndDetach))
+	if err := attached.Start(); err != nil {
+		shutdownDBAndFail(t, err, original)
+	}
+
+	assert.Equal(t, uint32(9841), attached.Port())
+
+	info, err := attached.Info()
+	if err != nil {
+		shutdownDBAndFail(t, err, original)
+	}
+
+	assert.True(t, info.Attached)
+
+	if err := attached.Stop(); err != nil {
+		shutdownDBAndFail(t, err, original)
+	}
+
+	assert.Equal(t, StateStopped, attached.State())
+
+	db, err := sql.Open("postgres", original.GetConnectionURL()+"?sslmode=disable")
+	if err != nil {
+		shutdownDBAndFail(t, err, original)
+	}
+
+	if err := db.Ping(); err != nil {
+		shutdownDBAndFail(t, err, original)
+	}
+
+	if err := db.Close(); err != nil {
+		shutdownDBAndFail(t, err, original)
+	}
+
+	if err := original.Stop(); err != nil {
+		shutdownDBAndFail(t, err, original)
+	}
+}
+
+func Test_Attach_StopStopsServer(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "embedded_postgres_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	config := DefaultConfig().
+		Port(9842).
+		RuntimePath(filepath.Join(tempDir, "runtime")).
+		DataPath(filepath.Join(tempDir, "data"))
+
+	original := NewDatabase(config)
+	if err := original.Start(); err != nil {
+		shutdownDBAndFail(t, err, original)
+	}
+
+	attached := NewDatabase(config.Attach(AttachAndStop))
+	if err := attached.Start(); err != nil {
+		shutdownDBAndFail(t, err, original)
+	}
+
+	if err := attached.Stop(); err != nil {
+		shutdownDBAndFail(t, err, original)
+	}
+
+	_, exists, err := readPostmasterPidFile(filepath.Join(tempDir, "data"))
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func Test_Attach_ErrorWhenCredentialsDoNotMatch(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "embedded_postgres_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	config := DefaultConfig().
+		Port(9843).
+		RuntimePath(filepath.Join(tempDir, "runtime")).
+		DataPath(filepath.Join(tempDir, "data"))
+
+	original := NewDatabase(config)
+	if err := original.Start(); err != nil {
+		shutdownDBAndFail(t, err, original)
+	}
+
+	attached := NewDatabase(config.
+		Password("wrong").
+		Attach(AttachAndDetach))
+
+	err = attached.Start()
+
+	assert.ErrorIs(t, err, ErrAttachFailed)
+
+	if err := original.Stop(); err != nil {
+		shutdownDBAndFail(t, err, original)
+	}
+}
@@ -3,27 +3,57 @@ package embeddedpostgres
 import (
 	"fmt"
 	"io"
+	"net"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
 // Config maintains the runtime configuration for the Postgres process to be created.
 type Config struct {
-	version             PostgresVersion
-	port                uint32
-	database            string
-	username            string
-	password            string
-	cachePath           string
-	runtimePath         string
-	dataPath            string
-	binariesPath        string
-	locale              string
-	encoding            string
-	startParameters     map[string]string
-	binaryRepositoryURL string
-	startTimeout        time.Duration
-	logger              io.Writer
+	version                 PostgresVersion
+	port                    uint32
+	portRangeStart          uint32
+	portRangeEnd            uint32
+	database                string
+	username                string
+	password                string
+	cachePath               string
+	runtimePath             string
+	dataPath                string
+	binariesPath            string
+	locale                  string
+	encoding                string
+	startParameters         map[string]string
+	binaryRepositoryURL     string
+	startTimeout            time.Duration
+	stopTimeout             time.Duration
+	shutdownMode            ShutdownMode
+	supervised              bool
+	stopOnProcessExit       bool
+	unixSocket              bool
+	unixSocketDirectory     string
+	disableTCP              bool
+	listenAddresses         []string
+	advertisedHost          string
+	osUser                  string
+	eventHandler            func(Event)
+	beforeInit              Hook
+	afterInit               Hook
+	afterStart              Hook
+	beforeStop              Hook
+	forceRuntimePathCleanup bool
+	dataPathPolicy          DataPathPolicy
+	attachMode              AttachMode
+	logDirectory            string
+	cleanupOnStop           bool
+	initDB                  initDBOptions
+	databases               []DatabaseSpec
+	roles                   []RoleSpec
+	initScriptsDir          string
+	logger                  io.Writer
 }
 
 // DefaultConfig provides a default set of configuration to be used "as is" or modified using the provided builders.
@@ -54,17 +84,42 @@ func (c Config) Version(version PostgresVersion) Config {
 }
 
 // Port sets the runtime port that Postgres can be accessed on.
+// A port of 0 will choose a free port each time Postgres is started, see EmbeddedPostgres.Port for the chosen port.
 func (c Config) Port(port uint32) Config {
 	c.port = port
 	return c
 }
 
+// PortRange limits the free port chosen when Port is set to 0 to the inclusive range from start to end.
+func (c Config) PortRange(start, end uint32) Config {
+	c.portRangeStart = start
+	c.portRangeEnd = end
+
+	return c
+}
+
 // Database sets the database name that will be created.
 func (c Config) Database(database string) Config {
 	c.database = database
 	return c
 }
 
+// Databases sets additional databases that Start creates after the configured Database, each unless it already
+// exists, see EmbeddedPostgres.GetDatabaseConnectionURL for their connection URLs. They are not created when Start
+// attaches to a running server, see Attach.
+func (c Config) Databases(databases ...DatabaseSpec) Config {
+	c.databases = append([]DatabaseSpec(nil), databases...)
+	return c
+}
+
+// Roles sets roles that Start creates before the Databases, granting them their privileges once the Databases exist,
+// see EmbeddedPostgres.GetRoleConnectionURL for their connection URLs. They are not created when Start attaches to a
+// running server, see Attach.
+func (c Config) Roles(roles ...RoleSpec) Config {
+	c.roles = append([]RoleSpec(nil), roles...)
+	return c
+}
+
 // Username sets the username that will be used to connect.
 func (c Config) Username(username string) Config {
 	c.username = username
@@ -79,11 +134,20 @@ func (c Config) Password(password string) Config {
 
 // RuntimePath sets the path that will be used for the extracted Postgres runtime directory.
 // If Postgres data directory is not set with DataPath(), this directory is also used as data directory.
+// The directory is deleted by every Start, which refuses to delete a non-empty directory that it did not create unless
+// ForceRuntimePathCleanup is set.
 func (c Config) RuntimePath(path string) Config {
 	c.runtimePath = path
 	return c
 }
 
+// ForceRuntimePathCleanup allows Start to delete a non-empty RuntimePath that was not created by embedded-postgres,
+// for example one created by an older version of this library.
+func (c Config) ForceRuntimePathCleanup(force bool) Config {
+	c.forceRuntimePathCleanup = force
+	return c
+}
+
 // CachePath sets the path that will be used for storing Postgres binaries archive.
 // If this option is not set, ~/.go-embedded-postgres will be used.
 func (c Config) CachePath(path string) Config {
@@ -132,20 +196,428 @@ func (c Config) StartTimeout(timeout time.Duration) Config {
 	return c
 }
 
+// StopTimeout sets the max time Stop waits for Postgres to shut down using the configured ShutdownMode.
+// Once it expires Stop escalates to the next more forceful shutdown mode, each with the same timeout, and finally kills
+// the process, returning a StopTimeoutError listing the connections that were open when Stop began.
+// When left unset pg_ctl gives up waiting after its own timeout, 60 seconds unless PGCTLTIMEOUT is set, and Stop
+// returns its error without escalating, leaving Postgres running.
+func (c Config) StopTimeout(timeout time.Duration) Config {
+	c.stopTimeout = timeout
+	return c
+}
+
+// ShutdownMode sets how Stop treats connected clients, see ShutdownSmart, ShutdownFast and ShutdownImmediate.
+// When left unset the pg_ctl default of ShutdownFast is used.
+// With a StopTimeout, Stop escalates from the configured mode: smart to fast to immediate, fast to immediate, or
+// immediate alone, before killing the process.
+func (c Config) ShutdownMode(mode ShutdownMode) Config {
+	c.shutdownMode = mode
+	return c
+}
+
+// DataPathPolicy sets what Start does when the DataPath was initialised by a different major version of Postgres, see
+// DataPathReinitialize, DataPathFail and DataPathBackup.
+// When left unset the data directory is deleted and initialised again.
+func (c Config) DataPathPolicy(policy DataPathPolicy) Config {
+	c.dataPathPolicy = policy
+	return c
+}
+
+// Attach makes Start attach to a Postgres server that is already running on the DataPath, for example one left running
+// by an earlier process, instead of failing because its port is in use. Start detects the server using postmaster.pid
+// and pg_ctl status and checks that it accepts the configured credentials. The mode selects whether Stop stops the
+// server or only detaches from it, see AttachAndStop and AttachAndDetach.
+// An attached server is used as it is: Start does not create the Databases and Roles, run the InitScriptsDir or run the
+// AfterStart hook, as the process that started it already did.
+// When left unset a server started from the configured BinariesPath is stopped before starting a new one.
+func (c Config) Attach(mode AttachMode) Config {
+	c.attachMode = mode
+	return c
+}
+
+// Supervised runs the postgres binary directly as a child process instead of starting it in the background with pg_ctl.
+// This allows EmbeddedPostgres.Done, EmbeddedPostgres.Wait and EmbeddedPostgres.ExitCode to report when the server
+// exits unexpectedly, for example when it crashes part way through a test.
+// Postgres refuses to run as a user with administrative permissions on Windows when not started through pg_ctl.
+func (c Config) Supervised(supervised bool) Config {
+	c.supervised = supervised
+	return c
+}
+
+// StopOnProcessExit shuts Postgres down automatically when the Go process exits by any route, including a panic,
+// os.Exit or being killed, rather than leaving the server running. It implies Supervised.
+// This is only supported on Linux, where Postgres is sent SIGINT for a fast shutdown when its parent dies.
+func (c Config) StopOnProcessExit(stop bool) Config {
+	c.stopOnProcessExit = stop
+	return c
+}
+
+// UnixSocket makes Postgres listen on a Unix domain socket in a private directory created for each start, and connects
+// through it rather than over TCP. See UnixSocketDirectory to choose the directory instead.
+func (c Config) UnixSocket(enabled bool) Config {
+	c.unixSocket = enabled
+	return c
+}
+
+// UnixSocketDirectory makes Postgres listen on a Unix domain socket in dir, creating it when missing and owned by the
+// OSUser, and connects through it rather than over TCP. The directory path must be short enough for the socket path to fit the operating
+// system limit of around 100 characters.
+func (c Config) UnixSocketDirectory(dir string) Config {
+	c.unixSocket = true
+	c.unixSocketDirectory = dir
+
+	return c
+}
+
+// TCP sets whether Postgres also listens on TCP when listening on a Unix socket, which it does by default.
+// Disabling TCP avoids port conflicts completely, it requires UnixSocket or UnixSocketDirectory.
+func (c Config) TCP(enabled bool) Config {
+	c.disableTCP = !enabled
+	return c
+}
+
+// ListenAddresses sets the addresses Postgres listens on for TCP connections, which is only localhost by default.
+// Use "0.0.0.0" or "::" to listen on all IPv4 or IPv6 interfaces, for example to be reachable from other containers,
+// or "*" for all interfaces. Starting checks that the port is free on each of the addresses.
+func (c Config) ListenAddresses(addresses ...string) Config {
+	c.listenAddresses = addresses
+	return c
+}
+
+// AdvertisedHost sets the host used in GetConnectionURL, for example the name other containers reach this one by.
+// When left unset the first of the ListenAddresses is used, with wildcard addresses replaced by the loopback address.
+func (c Config) AdvertisedHost(host string) Config {
+	c.advertisedHost = host
+	return c
+}
+
+// OSUser runs initdb and Postgres as the named operating system user, or the user with the given numeric uid, and
+// changes the owner of the runtime, data and binaries directories to that user. This allows starting Postgres from a
+// process running as root, which initdb and Postgres refuse to run as. The user needs access to every parent directory
+// of those paths, so they should not be placed in the home directory of root.
+// It has no effect when the Go process already runs as that user and is not supported on Windows.
+func (c Config) OSUser(user string) Config {
+	c.osUser = user
+	return c
+}
+
+// EventHandler sets a function that is called as each phase of Start begins and ends, for example to log progress.
+// It is called synchronously from the goroutine calling Start, so it should return quickly.
+func (c Config) EventHandler(handler func(Event)) Config {
+	c.eventHandler = handler
+	return c
+}
+
+// BeforeInit sets a Hook that runs before initdb initialises a new data directory. It does not run when an existing
+// data directory is reused.
+func (c Config) BeforeInit(hook Hook) Config {
+	c.beforeInit = hook
+	return c
+}
+
+// AfterInit sets a Hook that runs once initdb has initialised a new data directory, before Postgres is started, for
+// example to edit pg_hba.conf. It does not run when an existing data directory is reused, so when BeforeInit or
+// AfterInit fail the new data directory is removed again.
+func (c Config) AfterInit(hook Hook) Config {
+	c.afterInit = hook
+	return c
+}
+
+// AfterStart sets a Hook that runs at the end of every Start that starts Postgres, once the database has been created
+// and accepts connections, for example to run migrations. When it fails Postgres is stopped again. It does not run when
+// Start attaches to a running server, see Attach. Calling Stop from the hook aborts Start without waiting for it, see
+// EmbeddedPostgres.StopContext.
+func (c Config) AfterStart(hook Hook) Config {
+	c.afterStart = hook
+	return c
+}
+
+// BeforeStop sets a Hook that runs at the start of Stop while the database still accepts connections, for example to
+// dump statistics. When it fails Postgres is still stopped and Stop returns the hook error.
+func (c Config) BeforeStop(hook Hook) Config {
+	c.beforeStop = hook
+	return c
+}
+
+// InitScriptsDir sets a directory of scripts that Start runs once the data directory has been initialised, the way the
+// official Docker image runs /docker-entrypoint-initdb.d. Files run in lexical order, *.sql and *.sql.gz files using
+// psql against the configured Database and *.sh files using sh with the PG* environment variables set. Scripts do not
+// run again when the data directory is reused, when one fails the new data directory is removed so that they do.
+func (c Config) InitScriptsDir(dir string) Config {
+	c.initScriptsDir = dir
+	return c
+}
+
+// DataChecksums enables checksums on data pages to detect corruption, see initdb --data-checksums.
+func (c Config) DataChecksums(enabled bool) Config {
+	c.initDB.dataChecksums = enabled
+	return c
+}
+
+// WALSegmentSize sets the size of WAL segments in megabytes, which must be a power of 2 between 1 and 1024, see
+// initdb --wal-segsize.
+func (c Config) WALSegmentSize(megabytes uint32) Config {
+	c.initDB.walSegmentSize = megabytes
+	return c
+}
+
+// WALDir places the write-ahead log in a directory outside the data directory, see initdb --waldir.
+// It is cleaned up together with the data directory it belongs to whenever that is initialised again, Start fails
+// rather than initialise a data directory when it holds files of any other.
+func (c Config) WALDir(path string) Config {
+	c.initDB.walDir = path
+	return c
+}
+
+// ICULocale initialises the databases with the ICU locale provider using the given ICU locale, for example "en-US",
+// see initdb --locale-provider=icu and --icu-locale. Requires Postgres 15 or later.
+func (c Config) ICULocale(locale string) Config {
+	c.initDB.icuLocale = locale
+	return c
+}
+
+// LCCollate sets the collation order of the databases, overriding Locale, see initdb --lc-collate.
+func (c Config) LCCollate(locale string) Config {
+	c.initDB.lcCollate = locale
+	return c
+}
+
+// LCCtype sets the character classification of the databases, overriding Locale, see initdb --lc-ctype.
+func (c Config) LCCtype(locale string) Config {
+	c.initDB.lcCtype = locale
+	return c
+}
+
+// AuthLocal sets the authentication method of local connections over a Unix socket, see initdb --auth-local.
+// When left unset local connections require a password.
+func (c Config) AuthLocal(method AuthMethod) Config {
+	c.initDB.authLocal = method
+	return c
+}
+
+// AuthHost sets the authentication method of TCP connections, see initdb --auth-host.
+// When left unset TCP connections require a password.
+func (c Config) AuthHost(method AuthMethod) Config {
+	c.initDB.authHost = method
+	return c
+}
+
+// NoSync skips waiting for initdb to write its files safely to disk, which speeds up initialisation of throwaway
+// instances at the risk of a corrupt data directory after an operating system crash, see initdb --no-sync.
+func (c Config) NoSync(noSync bool) Config {
+	c.initDB.noSync = noSync
+	return c
+}
+
+// InitDBArgs passes additional arguments to initdb after all others, for options without a dedicated method.
+func (c Config) InitDBArgs(args ...string) Config {
+	c.initDB.extraArgs = append([]string(nil), args...)
+	return c
+}
+
 // Logger sets the logger for postgres output
 func (c Config) Logger(logger io.Writer) Config {
 	c.logger = logger
 	return c
 }
 
+// LogDirectory sets the directory the Postgres log file is written to. When left unset the log file is written to the
+// runtime directory. The log file is deleted when Stop succeeds and kept when Start or Stop fail, see
+// EmbeddedPostgres.LogFile.
+func (c Config) LogDirectory(dir string) Config {
+	c.logDirectory = dir
+	return c
+}
+
+// CleanupOnStop deletes the runtime and data directories when Stop succeeds, for throwaway instances.
+func (c Config) CleanupOnStop(cleanup bool) Config {
+	c.cleanupOnStop = cleanup
+	return c
+}
+
 // BinaryRepositoryURL set BinaryRepositoryURL to fetch PG Binary in case of Maven proxy
 func (c Config) BinaryRepositoryURL(binaryRepositoryURL string) Config {
 	c.binaryRepositoryURL = binaryRepositoryURL
 	return c
 }
 
+// GetConnectionURL returns the connection URL for the configured database using the AdvertisedHost. Without one the
+// URL names the socket directory with the host query parameter when listening on a Unix socket. The username,
+// password and database are escaped as needed.
 func (c Config) GetConnectionURL() string {
-	return fmt.Sprintf("postgresql://%s:%s@%s:%d/%s", c.username, c.password, "localhost", c.port, c.database)
+	connectionURL := url.URL{
+		Scheme: "postgresql",
+		User:   url.UserPassword(c.username, c.password),
+		Path:   "/" + c.database,
+	}
+
+	if c.advertisedHost == "" && c.unixSocketDirectory != "" {
+		connectionURL.Host = fmt.Sprintf(":%d", c.port)
+		connectionURL.RawQuery = "host=" + url.QueryEscape(c.unixSocketDirectory)
+
+		return connectionURL.String()
+	}
+
+	host := c.advertisedHost
+	if host == "" {
+		host = c.tcpConnectionHost()
+	}
+
+	connectionURL.Host = net.JoinHostPort(host, strconv.FormatUint(uint64(c.port), 10))
+
+	return connectionURL.String()
+}
+
+// GetDatabaseConnectionURL returns the connection URL for the named database in place of the configured Database.
+func (c Config) GetDatabaseConnectionURL(database string) string {
+	c.database = database
+	return c.GetConnectionURL()
+}
+
+// GetRoleConnectionURL returns the connection URL for the named database as the named role, using its password from
+// the Roles list.
+func (c Config) GetRoleConnectionURL(role, database string) string {
+	c.password = c.rolePassword(role)
+	c.username = role
+
+	return c.GetDatabaseConnectionURL(database)
+}
+
+// rolePassword returns the password of the named role from the Roles list, or the configured password for the
+// configured username.
+func (c Config) rolePassword(role string) string {
+	if role == c.username {
+		return c.password
+	}
+
+	for _, spec := range c.roles {
+		if spec.Name == role {
+			return spec.Password
+		}
+	}
+
+	return ""
+}
+
+// GetVersion returns the configured Postgres version.
+func (c Config) GetVersion() PostgresVersion {
+	return c.version
+}
+
+// GetPort returns the configured port. On the Config returned by EmbeddedPostgres.Config this is the port in use.
+func (c Config) GetPort() uint32 {
+	return c.port
+}
+
+// GetUsername returns the configured username.
+func (c Config) GetUsername() string {
+	return c.username
+}
+
+// GetDatabase returns the configured database name.
+func (c Config) GetDatabase() string {
+	return c.database
+}
+
+// GetCachePath returns the configured cache path, which is empty when the default location is used.
+func (c Config) GetCachePath() string {
+	return c.cachePath
+}
+
+// GetRuntimePath returns the runtime path. On the Config returned by EmbeddedPostgres.Config this includes the default
+// chosen by Start.
+func (c Config) GetRuntimePath() string {
+	return c.runtimePath
+}
+
+// GetDataPath returns the data path. On the Config returned by EmbeddedPostgres.Config this includes the default chosen
+// by Start.
+func (c Config) GetDataPath() string {
+	return c.dataPath
+}
+
+// GetBinariesPath returns the binaries path. On the Config returned by EmbeddedPostgres.Config this includes the
+// default chosen by Start.
+func (c Config) GetBinariesPath() string {
+	return c.binariesPath
+}
+
+// GetStartParameters returns a copy of the configured start parameters.
+func (c Config) GetStartParameters() map[string]string {
+	parameters := make(map[string]string, len(c.startParameters))
+	for k, v := range c.startParameters {
+		parameters[k] = v
+	}
+
+	return parameters
+}
+
+// connectionHost returns the host to connect to Postgres on, which is the socket directory when listening on a Unix socket.
+func (c Config) connectionHost() string {
+	if c.unixSocketDirectory != "" {
+		return c.unixSocketDirectory
+	}
+
+	return c.tcpConnectionHost()
+}
+
+// tcpConnectionHost returns the first listen address, with wildcard addresses replaced by the matching loopback address.
+func (c Config) tcpConnectionHost() string {
+	if len(c.listenAddresses) == 0 {
+		return "localhost"
+	}
+
+	switch address := strings.TrimSpace(c.listenAddresses[0]); address {
+	case "*", "":
+		return "localhost"
+	case "0.0.0.0":
+		return "127.0.0.1"
+	case "::":
+		return "::1"
+	default:
+		return address
+	}
+}
+
+// probeAddresses returns the hosts to check the port is free on before starting Postgres, an empty host checking all
+// interfaces.
+func (c Config) probeAddresses() []string {
+	if len(c.listenAddresses) == 0 {
+		return []string{"localhost"}
+	}
+
+	addresses := make([]string, 0, len(c.listenAddresses))
+	for _, address := range c.listenAddresses {
+		if address = strings.TrimSpace(address); address == "*" {
+			address = ""
+		}
+
+		addresses = append(addresses, address)
+	}
+
+	return addresses
+}
+
+// serverParameters returns the StartParameters together with the parameters derived from the rest of the configuration.
+func (c Config) serverParameters() map[string]string {
+	parameters := make(map[string]string, len(c.startParameters)+3)
+	for k, v := range c.startParameters {
+		parameters[k] = v
+	}
+
+	if c.unixSocketDirectory != "" {
+		parameters["unix_socket_directories"] = c.unixSocketDirectory
+	}
+
+	if len(c.listenAddresses) > 0 {
+		parameters["listen_addresses"] = strings.Join(c.listenAddresses, ",")
+	}
+
+	if c.disableTCP {
+		parameters["listen_addresses"] = ""
+	}
+
+	return parameters
 }
 
 // PostgresVersion represents the semantic version used to fetch and run the Postgres process.
@@ -0,0 +1,172 @@
+package embeddedpostgres
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DataPathPolicy selects what Start does when the DataPath holds a data directory initialised by a different major
+// version of Postgres than the configured Version.
+type DataPathPolicy string
+
+// Data path policies supported by Start.
+const (
+	// DataPathReinitialize deletes the data directory and initialises a new one, the default.
+	DataPathReinitialize = DataPathPolicy("reinitialize")
+	// DataPathFail leaves the data directory untouched and makes Start return a DataVersionMismatchError.
+	DataPathFail = DataPathPolicy("fail")
+	// DataPathBackup moves the data directory aside to a timestamped backup next to it and initialises a new one.
+	DataPathBackup = DataPathPolicy("backup")
+)
+
+// prepareDataPath reports whether the data directory can be reused, applying the configured DataPathPolicy when it was
+// initialised by a different major version of Postgres.
+func (ep *EmbeddedPostgres) prepareDataPath() (bool, error) {
+	dataPath := ep.config.dataPath
+
+	found, ok := dataDirVersion(dataPath)
+	if !ok {
+		return false, nil
+	}
+
+	expected := majorVersion(ep.config.version)
+	if found == expected {
+		return true, nil
+	}
+
+	switch ep.config.dataPathPolicy {
+	case "", DataPathReinitialize:
+		// the data directory is deleted before initialising a new one
+	case DataPathFail:
+		return false, &DataVersionMismatchError{DataPath: dataPath, Found: found, Expected: expected}
+	case DataPathBackup:
+		suffix := ".backup-" + time.Now().Format("20060102T150405")
+		backupPath := filepath.Clean(dataPath) + suffix
+
+		if err := os.Rename(dataPath, backupPath); err != nil {
+			return false, fmt.Errorf("unable to back up data directory %s to %s with error: %w", dataPath, backupPath, err)
+		}
+
+		// the WAL directory would otherwise be cleaned up before initialising again, so it moves along with the backup
+		if walDir := ep.config.initDB.walDir; walDir != "" {
+			if err := backUpWALDir(walDir, filepath.Clean(walDir)+suffix, backupPath); err != nil {
+				return false, err
+			}
+		}
+
+		if _, err := fmt.Fprintf(ep.syncedLogger.file, "data directory %s was initialised by Postgres %s and has been moved to %s\n",
+			dataPath, found, backupPath); err != nil {
+			return false, fmt.Errorf("unable to write to log with error: %w", err)
+		}
+	default:
+		return false, fmt.Errorf("%w %q", ErrUnknownDataPathPolicy, ep.config.dataPathPolicy)
+	}
+
+	return false, nil
+}
+
+// backUpWALDir moves the WAL directory to walBackupPath and links the backed up data directory to it again. A WAL
+// directory that the backed up data directory is not linked to is left in place.
+func backUpWALDir(walDir, walBackupPath, backupPath string) error {
+	if !walDirLinked(backupPath, walDir) {
+		return nil
+	}
+
+	if err := os.Rename(walDir, walBackupPath); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return fmt.Errorf("unable to back up WAL directory %s to %s with error: %w", walDir, walBackupPath, err)
+	}
+
+	walBackupPath, err := filepath.Abs(walBackupPath)
+	if err != nil {
+		return fmt.Errorf("unable to resolve WAL directory %s with error: %w", walBackupPath, err)
+	}
+
+	link := filepath.Join(backupPath, "pg_wal")
+	if err := os.Remove(link); err != nil {
+		return fmt.Errorf("unable to link %s to %s with error: %w", link, walBackupPath, err)
+	}
+
+	if err := os.Symlink(walBackupPath, link); err != nil {
+		return fmt.Errorf("unable to link %s to %s with error: %w", link, walBackupPath, err)
+	}
+
+	return nil
+}
+
+// walDirLinked reports whether walDir is the WAL directory of the data directory at dataPath, which initdb links to it
+// through the pg_wal symlink.
+func walDirLinked(dataPath, walDir string) bool {
+	linked, err := os.Stat(filepath.Join(dataPath, "pg_wal"))
+	if err != nil {
+		return false
+	}
+
+	wal, err := os.Stat(walDir)
+	if err != nil {
+		return false
+	}
+
+	return os.SameFile(linked, wal)
+}
+
+// removeWALDir removes walDir when the data directory at dataPath, which is about to be removed, is linked to it. Any
+// other WAL directory may belong to a different cluster, so it is left alone and an error is returned unless it is
+// empty, as initdb would refuse to use it anyway.
+func removeWALDir(dataPath, walDir string) error {
+	if walDir == "" {
+		return nil
+	}
+
+	if walDirLinked(dataPath, walDir) {
+		if err := os.RemoveAll(walDir); err != nil {
+			return fmt.Errorf("unable to clean up WAL directory %s with error: %w", walDir, err)
+		}
+
+		return nil
+	}
+
+	entries, err := os.ReadDir(walDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return fmt.Errorf("unable to read WAL directory %s with error: %w", walDir, err)
+	}
+
+	if len(entries) > 0 {
+		return fmt.Errorf("WAL directory %s is not empty and does not belong to data directory %s", walDir, dataPath)
+	}
+
+	return nil
+}
+
+// dataDirVersion returns the major version of Postgres that initialised the data directory, as recorded in its
+// PG_VERSION file.
+func dataDirVersion(dataDir string) (string, bool) {
+	d, err := os.ReadFile(filepath.Join(dataDir, "PG_VERSION"))
+	if err != nil {
+		return "", false
+	}
+
+	return strings.TrimSpace(string(d)), true
+}
+
+// majorVersion returns the major version of a Postgres release the way PG_VERSION records it, which is the first
+// component from Postgres 10 onwards and the first two components before that.
+func majorVersion(version PostgresVersion) string {
+	components := strings.Split(string(version), ".")
+
+	if len(components) > 1 && len(components[0]) == 1 {
+		return components[0] + "." + components[1]
+	}
+
+	return components[0]
+}
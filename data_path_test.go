@@ -0,0 +1,173 @@
+package embeddedpostgres
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_majorVersion(t *testing.T) {
+	for version, expected := range map[PostgresVersion]string{
+		V18:                        "18",
+		V10:                        "10",
+		V9:                         "9.6",
+		PostgresVersion("9.5.25"):  "9.5",
+		PostgresVersion("16"):      "16",
+		PostgresVersion("1.2.3"):   "1.2",
+		PostgresVersion("160.0.0"): "160",
+	} {
+		assert.Equal(t, expected, majorVersion(version), string(version))
+	}
+}
+
+func Test_prepareDataPath_ReusesMatchingVersion(t *testing.T) {
+	database, dataPath := dataPathTestDatabase(t, "16\n", DataPathFail)
+
+	reuse, err := database.prepareDataPath()
+
+	assert.NoError(t, err)
+	assert.True(t, reuse)
+	assert.FileExists(t, filepath.Join(dataPath, "PG_VERSION"))
+}
+
+func Test_prepareDataPath_DoesNotMatchVersionPrefix(t *testing.T) {
+	database, _ := dataPathTestDatabase(t, "1\n", DataPathReinitialize)
+
+	reuse, err := database.prepareDataPath()
+
+	assert.NoError(t, err)
+	assert.False(t, reuse)
+}
+
+func Test_prepareDataPath_ReinitializesMismatchedVersion(t *testing.T) {
+	database, _ := dataPathTestDatabase(t, "15\n", "")
+
+	reuse, err := database.prepareDataPath()
+
+	assert.NoError(t, err)
+	assert.False(t, reuse)
+}
+
+func Test_prepareDataPath_FailsOnMismatchedVersion(t *testing.T) {
+	database, dataPath := dataPathTestDatabase(t, "9.6\n", DataPathFail)
+
+	reuse, err := database.prepareDataPath()
+
+	assert.False(t, reuse)
+	assert.ErrorIs(t, err, ErrDataVersionMismatch)
+	assert.EqualError(t, err, "data directory "+dataPath+" was initialised by Postgres 9.6 but version 16 is configured")
+	assert.FileExists(t, filepath.Join(dataPath, "PG_VERSION"))
+}
+
+func Test_prepareDataPath_ErrorOnUnknownPolicy(t *testing.T) {
+	database, dataPath := dataPathTestDatabase(t, "15\n", DataPathPolicy("delete"))
+
+	reuse, err := database.prepareDataPath()
+
+	assert.False(t, reuse)
+	assert.EqualError(t, err, `unknown data path policy "delete"`)
+	assert.ErrorIs(t, err, ErrUnknownDataPathPolicy)
+	assert.FileExists(t, filepath.Join(dataPath, "PG_VERSION"))
+}
+
+func Test_prepareDataPath_BacksUpMismatchedVersion(t *testing.T) {
+	database, dataPath := dataPathTestDatabase(t, "15\n", DataPathBackup)
+
+	reuse, err := database.prepareDataPath()
+
+	assert.NoError(t, err)
+	assert.False(t, reuse)
+	assert.NoDirExists(t, dataPath)
+
+	backups, err := filepath.Glob(dataPath + ".backup-*")
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+
+	content, err := os.ReadFile(filepath.Join(backups[0], "PG_VERSION"))
+	require.NoError(t, err)
+	assert.Equal(t, "15\n", string(content))
+
+	log, err := os.ReadFile(database.syncedLogger.file.Name())
+	require.NoError(t, err)
+	assert.True(t, strings.Contains(string(log), "has been moved to "+backups[0]), string(log))
+}
+
+func Test_prepareDataPath_BacksUpWALDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("links the data directory to the WAL directory using a symlink")
+	}
+
+	database, dataPath := dataPathTestDatabase(t, "15\n", DataPathBackup)
+
+	walDir := filepath.Join(filepath.Dir(dataPath), "wal")
+	require.NoError(t, os.MkdirAll(walDir, os.ModePerm))
+	require.NoError(t, os.WriteFile(filepath.Join(walDir, "000000010000000000000001"), nil, 0600))
+	require.NoError(t, os.Symlink(walDir, filepath.Join(dataPath, "pg_wal")))
+
+	database.config = database.config.WALDir(walDir)
+
+	_, err := database.prepareDataPath()
+	require.NoError(t, err)
+
+	assert.NoDirExists(t, walDir)
+
+	backups, err := filepath.Glob(dataPath + ".backup-*")
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+
+	assert.FileExists(t, filepath.Join(backups[0], "pg_wal", "000000010000000000000001"))
+}
+
+func Test_removeWALDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("links the data directory to the WAL directory using a symlink")
+	}
+
+	tempDir, err := os.MkdirTemp("", "data_path_test")
+	require.NoError(t, err)
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	dataPath := filepath.Join(tempDir, "data")
+	require.NoError(t, os.MkdirAll(dataPath, os.ModePerm))
+
+	walDir := filepath.Join(tempDir, "wal")
+	require.NoError(t, os.MkdirAll(walDir, os.ModePerm))
+
+	assert.NoError(t, removeWALDir(dataPath, walDir), "empty")
+	assert.DirExists(t, walDir)
+
+	require.NoError(t, os.WriteFile(filepath.Join(walDir, "000000010000000000000001"), nil, 0600))
+
+	err = removeWALDir(dataPath, walDir)
+	assert.EqualError(t, err, "WAL directory "+walDir+" is not empty and does not belong to data directory "+dataPath)
+	assert.FileExists(t, filepath.Join(walDir, "000000010000000000000001"))
+
+	require.NoError(t, os.Symlink(walDir, filepath.Join(dataPath, "pg_wal")))
+
+	assert.NoError(t, removeWALDir(dataPath, walDir), "linked")
+	assert.NoDirExists(t, walDir)
+
+	assert.NoError(t, removeWALDir(dataPath, walDir), "missing")
+	assert.NoError(t, removeWALDir(dataPath, ""), "not configured")
+}
+
+func dataPathTestDatabase(t *testing.T, pgVersion string, policy DataPathPolicy) (*EmbeddedPostgres, string) {
+	database, _ := fixtureDatabase(t, DefaultConfig().
+		Version(V16).
+		DataPathPolicy(policy), nil)
+
+	dataPath := database.config.dataPath
+	require.NoError(t, os.WriteFile(filepath.Join(dataPath, "PG_VERSION"), []byte(pgVersion), 0600))
+
+	return database, dataPath
+}
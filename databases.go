@@ -0,0 +1,119 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// DatabaseSpec describes a database that Start creates in addition to the configured Database, unless it exists.
+// Only Name is required, any other field left empty takes the Postgres default.
+type DatabaseSpec struct {
+	Name  string
+	Owner string
+	// Template defaults to template0 when Encoding, LCCollate or LCCtype are set, as template1 only allows copying it
+	// with its own encoding and locale.
+	Template  string
+	Encoding  string
+	LCCollate string
+	LCCtype   string
+	// ConnectionLimit limits the number of concurrent connections, zero leaves them unlimited.
+	ConnectionLimit int
+}
+
+func (d DatabaseSpec) createStatement() string {
+	statement := []string{"CREATE DATABASE", pq.QuoteIdentifier(d.Name)}
+
+	if d.Owner != "" {
+		statement = append(statement, "OWNER", pq.QuoteIdentifier(d.Owner))
+	}
+
+	template := d.Template
+	if template == "" && (d.Encoding != "" || d.LCCollate != "" || d.LCCtype != "") {
+		template = "template0"
+	}
+
+	if template != "" {
+		statement = append(statement, "TEMPLATE", pq.QuoteIdentifier(template))
+	}
+
+	if d.Encoding != "" {
+		statement = append(statement, "ENCODING", pq.QuoteLiteral(d.Encoding))
+	}
+
+	if d.LCCollate != "" {
+		statement = append(statement, "LC_COLLATE", pq.QuoteLiteral(d.LCCollate))
+	}
+
+	if d.LCCtype != "" {
+		statement = append(statement, "LC_CTYPE", pq.QuoteLiteral(d.LCCtype))
+	}
+
+	if d.ConnectionLimit != 0 {
+		statement = append(statement, "CONNECTION LIMIT", fmt.Sprintf("%d", d.ConnectionLimit))
+	}
+
+	return strings.Join(statement, " ")
+}
+
+// createDatabases creates the Roles, then the configured Database when the data directory was freshly initialised,
+// followed by every database of the Databases list that does not exist yet, and finally grants the Roles their
+// privileges in them.
+func (ep *EmbeddedPostgres) createDatabases(ctx context.Context, reuseData bool) error {
+	if len(ep.config.roles) > 0 {
+		if err := createRoles(ctx, ep.config); err != nil {
+			return err
+		}
+	}
+
+	if !reuseData {
+		if err := ep.createDatabase(ctx, ep.config.connectionHost(), ep.config.port, ep.config.username, ep.config.password, ep.config.database); err != nil {
+			return err
+		}
+	}
+
+	if len(ep.config.databases) > 0 {
+		if err := createDatabaseSpecs(ctx, ep.config); err != nil {
+			return err
+		}
+	}
+
+	if len(ep.config.roles) > 0 {
+		return grantRoles(ctx, ep.config)
+	}
+
+	return nil
+}
+
+func createDatabaseSpecs(ctx context.Context, config Config) error {
+	return withDatabase(config, "postgres", func(db *sql.DB) error {
+		for _, spec := range config.databases {
+			var exists bool
+			if err := db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM pg_database WHERE datname = $1)", spec.Name).Scan(&exists); err != nil {
+				return errorCustomDatabase(spec.Name, err)
+			}
+
+			if exists {
+				continue
+			}
+
+			if _, err := db.ExecContext(ctx, spec.createStatement()); err != nil {
+				return errorCustomDatabase(spec.Name, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// GetDatabaseConnectionURL returns the connection URL for the named database, such as one of the Databases list, using
+// the configured credentials.
+func (ep *EmbeddedPostgres) GetDatabaseConnectionURL(database string) string {
+	ep.stateMu.Lock()
+	defer ep.stateMu.Unlock()
+
+	return ep.config.GetDatabaseConnectionURL(database)
+}
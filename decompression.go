@@ -2,7 +2,7 @@ package embeddedpostgres
 
 import (
 	"archive/tar"
-	"fmt"
+	"context"
 	"io"
 	"os"
 	"path/filepath"
@@ -20,7 +20,7 @@ func defaultTarReader(xzReader *xz.Reader) (func() (*tar.Header, error), func()
 		}
 }
 
-func decompressTarXz(tarReader func(*xz.Reader) (func() (*tar.Header, error), func() io.Reader), path, extractPath string) error {
+func decompressTarXz(ctx context.Context, tarReader func(*xz.Reader) (func() (*tar.Header, error), func() io.Reader), path, extractPath string) error {
 	extractDirectory := filepath.Dir(extractPath)
 
 	if err := os.MkdirAll(extractDirectory, os.ModePerm); err != nil {
@@ -56,6 +56,10 @@ func decompressTarXz(tarReader func(*xz.Reader) (func() (*tar.Header, error), fu
 	readNext, reader := tarReader(xzReader)
 
 	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		header, err := readNext()
 
 		if err == io.EOF {
@@ -116,9 +120,5 @@ func decompressTarXz(tarReader func(*xz.Reader) (func() (*tar.Header, error), fu
 }
 
 func errorUnableToExtract(cacheLocation, binariesPath string, err error) error {
-	return fmt.Errorf("unable to extract postgres archive %s to %s, if running parallel tests, configure RuntimePath to isolate testing directories, %w",
-		cacheLocation,
-		binariesPath,
-		err,
-	)
+	return &ExtractError{Archive: cacheLocation, Path: binariesPath, Err: err}
 }
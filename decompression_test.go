@@ -2,6 +2,7 @@ package embeddedpostgres
 
 import (
 	"archive/tar"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -28,7 +29,7 @@ func Test_decompressTarXz(t *testing.T) {
 	archive, cleanUp := createTempXzArchive()
 	defer cleanUp()
 
-	err = decompressTarXz(defaultTarReader, archive, tempDir)
+	err = decompressTarXz(context.Background(), defaultTarReader, archive, tempDir)
 
 	assert.NoError(t, err)
 
@@ -42,7 +43,7 @@ func Test_decompressTarXz(t *testing.T) {
 }
 
 func Test_decompressTarXz_ErrorWhenFileNotExists(t *testing.T) {
-	err := decompressTarXz(defaultTarReader, "/does-not-exist", "/also-fake")
+	err := decompressTarXz(context.Background(), defaultTarReader, "/does-not-exist", "/also-fake")
 
 	assert.Error(t, err)
 	assert.Contains(
@@ -64,7 +65,7 @@ func Test_decompressTarXz_ErrorWhenErrorDuringRead(t *testing.T) {
 	archive, cleanUp := createTempXzArchive()
 	defer cleanUp()
 
-	err = decompressTarXz(func(reader *xz.Reader) (func() (*tar.Header, error), func() io.Reader) {
+	err = decompressTarXz(context.Background(), func(reader *xz.Reader) (func() (*tar.Header, error), func() io.Reader) {
 		return func() (*tar.Header, error) {
 			return nil, errors.New("oh noes")
 		}, nil
@@ -108,7 +109,7 @@ func Test_decompressTarXz_ErrorWhenFailedToReadFileToCopy(t *testing.T) {
 			}
 	}
 
-	err = decompressTarXz(fileBlockingExtractTarReader, archive, tempDir)
+	err = decompressTarXz(context.Background(), fileBlockingExtractTarReader, archive, tempDir)
 
 	assert.Regexp(t, "^unable to extract postgres archive:.+$", err)
 }
@@ -145,7 +146,7 @@ func Test_decompressTarXz_ErrorWhenFileToCopyToNotExists(t *testing.T) {
 			}
 	}
 
-	err = decompressTarXz(fileBlockingExtractTarReader, archive, tempDir)
+	err = decompressTarXz(context.Background(), fileBlockingExtractTarReader, archive, tempDir)
 
 	assert.Regexp(t, "^unable to extract postgres archive:.+$", err)
 }
@@ -180,7 +181,7 @@ func Test_decompressTarXz_ErrorWhenArchiveCorrupted(t *testing.T) {
 		panic(err)
 	}
 
-	err = decompressTarXz(defaultTarReader, archive, tempDir)
+	err = decompressTarXz(context.Background(), defaultTarReader, archive, tempDir)
 
 	assert.EqualError(t, err, "unable to extract postgres archive: xz: data is corrupt")
 }
@@ -197,10 +198,29 @@ func Test_decompressTarXz_ErrorWithInvalidDestination(t *testing.T) {
 
 	op := fmt.Sprintf(path.Join(tempDir, "%c"), rune(0))
 
-	err = decompressTarXz(defaultTarReader, archive, op)
+	err = decompressTarXz(context.Background(), defaultTarReader, archive, op)
 	assert.EqualError(
 		t,
 		err,
 		fmt.Sprintf("unable to extract postgres archive: mkdir %s: invalid argument", op),
 	)
 }
+
+func Test_decompressTarXz_ErrorWhenContextCancelled(t *testing.T) {
+	archive, cleanUp := createTempXzArchive()
+	defer cleanUp()
+
+	tempDir, err := os.MkdirTemp("", "temp_tar_test")
+	require.NoError(t, err)
+	defer func() {
+		os.RemoveAll(tempDir)
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = decompressTarXz(ctx, defaultTarReader, archive, tempDir)
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.NoFileExists(t, filepath.Join(tempDir, "dir1", "dir2", "some_content"))
+}
@@ -1,9 +1,9 @@
 package embeddedpostgres
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -17,17 +17,41 @@ var mu sync.Mutex
 var (
 	ErrServerNotStarted     = errors.New("server has not been started")
 	ErrServerAlreadyStarted = errors.New("server is already started")
+	ErrServerStarting       = errors.New("server is starting")
+	ErrServerStopping       = errors.New("server is stopping")
 )
 
 // EmbeddedPostgres maintains all configuration and runtime functions for maintaining the lifecycle of one Postgres process.
+// It is safe for concurrent use, operations that change the lifecycle State return an error rather than block when
+// they are not valid in the current State.
 type EmbeddedPostgres struct {
-	config              Config
-	cacheLocator        CacheLocator
-	remoteFetchStrategy RemoteFetchStrategy
-	initDatabase        initDatabase
-	createDatabase      createDatabase
-	started             bool
-	syncedLogger        *syncedLogger
+	config                 Config
+	cacheLocator           CacheLocator
+	remoteFetchStrategy    RemoteFetchStrategy
+	initDatabase           initDatabase
+	createDatabase         createDatabase
+	requestedPort          uint32
+	dataReused             bool
+	syncedLogger           *syncedLogger
+	postmaster             *postmaster
+	done                   chan struct{}
+	privateSocketDirectory bool
+	runAs                  *osUser
+	startResult            StartResult
+	defaultRuntimePath     bool
+	attached               bool
+
+	// lifecycle is held for the duration of every operation on the Postgres process. The config, syncedLogger,
+	// postmaster and done fields are only written while holding both lifecycle and stateMu.
+	lifecycle sync.Mutex
+	// stateMu guards the fields below and is never held while waiting on the Postgres process.
+	stateMu           sync.Mutex
+	state             State
+	cancelStart       context.CancelFunc
+	startDone         chan struct{}
+	runningPostmaster *postmaster
+	// hookRunning is set while the AfterStart or BeforeStop hook runs, when the server is up but not StateRunning.
+	hookRunning bool
 }
 
 // NewDatabase creates a new EmbeddedPostgres struct that can be used to start and stop a Postgres process.
@@ -58,65 +82,193 @@ func newDatabaseWithConfig(config Config) *EmbeddedPostgres {
 		remoteFetchStrategy: remoteFetchStrategy,
 		initDatabase:        defaultInitDatabase,
 		createDatabase:      defaultCreateDatabase,
-		started:             false,
+		requestedPort:       config.port,
+		state:               StateStopped,
 	}
 }
 
 // Start will try to start the configured Postgres process returning an error when there were any problems with invocation.
 // If any error occurs Start will try to also Stop the Postgres process in order to not leave any sub-process running.
-//
-//nolint:funlen
 func (ep *EmbeddedPostgres) Start() error {
-	if ep.started {
-		return ErrServerAlreadyStarted
-	}
+	return ep.StartContext(context.Background())
+}
+
+// StartContext behaves like Start but will abort downloading, extracting, initialising and health checking the
+// Postgres process as soon as ctx is done, returning the context error.
+// Calling Stop while StartContext is in progress aborts it in the same way.
+func (ep *EmbeddedPostgres) StartContext(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	if err := ensurePortAvailable(ep.config.port); err != nil {
+	if err := ep.beginTransition(StateStarting, cancel, StateStopped, StateFailed); err != nil {
 		return err
 	}
 
-	logger, err := newSyncedLogger("", ep.config.logger)
+	ep.lifecycle.Lock()
+	defer ep.lifecycle.Unlock()
+
+	recorder := newPhaseRecorder(ep.config.eventHandler)
+	err := ep.start(withPhaseRecorder(ctx, recorder))
+
+	ep.stateMu.Lock()
+	ep.startResult = recorder.result()
+	ep.stateMu.Unlock()
+
 	if err != nil {
-		return errors.New("unable to create logger")
+		_ = ep.removePrivateSocketDirectory()
+
+		ep.endTransition(StateFailed)
+
+		return err
 	}
 
-	ep.syncedLogger = logger
+	ep.endTransition(StateRunning)
+
+	return nil
+}
+
+//nolint:funlen
+func (ep *EmbeddedPostgres) start(ctx context.Context) error {
+	recorder := phaseRecorderFromContext(ctx)
+
+	if ep.config.stopOnProcessExit {
+		if err := ensureLifetimeBindingSupported(); err != nil {
+			return err
+		}
+	}
+
+	ep.runAs = nil
+	if ep.config.osUser != "" {
+		runAs, err := lookupOSUser(ep.config.osUser)
+		if err != nil {
+			return err
+		}
+
+		ep.runAs = runAs
+	}
+
+	if ep.config.logDirectory != "" {
+		if err := os.MkdirAll(ep.config.logDirectory, os.ModePerm); err != nil {
+			return fmt.Errorf("unable to create log directory %s with error: %w", ep.config.logDirectory, err)
+		}
+	}
 
+	// without a log directory the log starts out in the temp directory and moves into the runtime directory once that
+	// has been cleaned
+	logger, err := newSyncedLogger(ep.config.logDirectory, ep.config.logger)
+	if err != nil {
+		return fmt.Errorf("unable to create logger with error: %w", err)
+	}
+
+	finishCacheLookup := recorder.begin(PhaseCacheLookup)
 	cacheLocation, cacheExists := ep.cacheLocator()
+	finishCacheLookup(nil)
+
+	ep.stateMu.Lock()
+	ep.syncedLogger = logger
 
 	if ep.config.runtimePath == "" {
 		ep.config.runtimePath = filepath.Join(filepath.Dir(cacheLocation), "extracted")
+		// the default runtime directory predates the ownership marker and is always safe to delete
+		ep.defaultRuntimePath = true
 	}
 
 	if ep.config.dataPath == "" {
 		ep.config.dataPath = filepath.Join(ep.config.runtimePath, "data")
 	}
 
-	if err := os.RemoveAll(ep.config.runtimePath); err != nil {
-		return fmt.Errorf("unable to clean up runtime directory %s with error: %s", ep.config.runtimePath, err)
-	}
-
 	if ep.config.binariesPath == "" {
 		ep.config.binariesPath = ep.config.runtimePath
 	}
 
-	if err := ep.downloadAndExtractBinary(cacheExists, cacheLocation); err != nil {
+	ep.attached = false
+	ep.stateMu.Unlock()
+
+	if ep.config.attachMode != "" {
+		attached, err := ep.attach(ctx)
+		if err != nil || attached {
+			return err
+		}
+	}
+
+	if err := ep.prepareUnixSocketDirectory(); err != nil {
+		return err
+	}
+
+	if err := reapStaleInstance(ctx, ep); err != nil {
+		return err
+	}
+
+	// the port stays reserved until Postgres has bound to it, a port of 0 chooses a new free port on every start
+	reservation, err := reservePort(ep.config.Port(ep.requestedPort))
+	if err != nil {
+		return err
+	}
+
+	defer reservation.release()
+
+	ep.stateMu.Lock()
+	ep.config.port = reservation.port
+	ep.stateMu.Unlock()
+
+	if err := ep.cleanRuntimePath(); err != nil {
+		return err
+	}
+
+	if err := ep.downloadAndExtractBinary(ctx, cacheExists, cacheLocation); err != nil {
 		return err
 	}
 
-	if err := os.MkdirAll(ep.config.runtimePath, os.ModePerm); err != nil {
-		return fmt.Errorf("unable to create runtime directory %s with error: %s", ep.config.runtimePath, err)
+	if err := ep.createRuntimePath(); err != nil {
+		return err
+	}
+
+	if ep.config.logDirectory == "" {
+		logger, err := ep.syncedLogger.moveTo(ep.config.runtimePath)
+		if err != nil {
+			return err
+		}
+
+		ep.stateMu.Lock()
+		ep.syncedLogger = logger
+		ep.stateMu.Unlock()
+	}
+
+	if err := ep.runAs.chownAll(ep.config.runtimePath, ep.config.binariesPath, ep.config.dataPath); err != nil {
+		return err
 	}
 
-	reuseData := dataDirIsValid(ep.config.dataPath, ep.config.version)
+	reuseData, err := ep.prepareDataPath()
+	if err != nil {
+		return err
+	}
+
+	ep.dataReused = reuseData
 
 	if !reuseData {
-		if err := ep.cleanDataDirectoryAndInit(); err != nil {
+		if err := ep.runHook(ctx, "BeforeInit", ep.config.beforeInit, false); err != nil {
+			return ep.discardDataDirectory(err)
+		}
+
+		finishInitDB := recorder.begin(PhaseInitDB)
+		err := ep.cleanDataDirectoryAndInit(ctx)
+		finishInitDB(err)
+
+		if err != nil {
 			return err
 		}
+
+		// a data directory that is kept would be reused by the next Start without running the hook again
+		if err := ep.runHook(ctx, "AfterInit", ep.config.afterInit, false); err != nil {
+			return ep.discardDataDirectory(err)
+		}
 	}
 
-	if err := startPostgres(ep); err != nil {
+	finishStart := recorder.begin(PhaseStart)
+	err = ep.startProcess(ctx)
+	finishStart(err)
+
+	if err != nil {
 		return err
 	}
 
@@ -124,21 +276,56 @@ func (ep *EmbeddedPostgres) Start() error {
 		return err
 	}
 
-	ep.started = true
+	if !reuseData || len(ep.config.databases) > 0 || len(ep.config.roles) > 0 {
+		finishCreateDatabase := recorder.begin(PhaseCreateDatabase)
+		err := ep.createDatabases(ctx, reuseData)
+		finishCreateDatabase(err)
 
-	if !reuseData {
-		if err := ep.createDatabase(ep.config.port, ep.config.username, ep.config.password, ep.config.database); err != nil {
-			if stopErr := stopPostgres(ep); stopErr != nil {
-				return fmt.Errorf("unable to stop database caused by error %s", err)
+		if err != nil {
+			if stopErr := stopPostgres(context.Background(), ep); stopErr != nil {
+				return fmt.Errorf("unable to stop database caused by error %w", err)
 			}
 
 			return err
 		}
 	}
 
-	if err := healthCheckDatabaseOrTimeout(ep.config); err != nil {
-		if stopErr := stopPostgres(ep); stopErr != nil {
-			return fmt.Errorf("unable to stop database caused by error %s", err)
+	finishHealthCheck := recorder.begin(PhaseHealthCheck)
+	err = healthCheckDatabaseOrTimeout(ctx, ep.config, ep.syncedLogger)
+	finishHealthCheck(err)
+
+	if err != nil {
+		if stopErr := stopPostgres(context.Background(), ep); stopErr != nil {
+			return fmt.Errorf("unable to stop database caused by error %w", err)
+		}
+
+		return err
+	}
+
+	if !reuseData && ep.config.initScriptsDir != "" {
+		finishInitScripts := recorder.begin(PhaseInitScripts)
+		err := ep.runInitScripts(ctx)
+		finishInitScripts(err)
+
+		if err != nil {
+			if stopErr := stopPostgres(context.Background(), ep); stopErr != nil {
+				return fmt.Errorf("unable to stop database with error %v caused by error %w", stopErr, err)
+			}
+
+			// the scripts only run on a new data directory, so it is removed for them to run again on the next Start
+			return ep.discardDataDirectory(err)
+		}
+	}
+
+	err = ep.runHook(ctx, "AfterStart", ep.config.afterStart, true)
+	if err == nil {
+		// Stop was called while the hook ran, without waiting for Start to finish
+		err = ctx.Err()
+	}
+
+	if err != nil {
+		if stopErr := stopPostgres(context.Background(), ep); stopErr != nil {
+			return fmt.Errorf("unable to stop database caused by error %w", err)
 		}
 
 		return err
@@ -147,32 +334,88 @@ func (ep *EmbeddedPostgres) Start() error {
 	return nil
 }
 
-func (ep *EmbeddedPostgres) downloadAndExtractBinary(cacheExists bool, cacheLocation string) error {
+func (ep *EmbeddedPostgres) downloadAndExtractBinary(ctx context.Context, cacheExists bool, cacheLocation string) error {
 	// lock to prevent collisions with duplicate downloads
 	mu.Lock()
 	defer mu.Unlock()
 
 	_, binDirErr := os.Stat(filepath.Join(ep.config.binariesPath, "bin", "pg_ctl"))
 	if os.IsNotExist(binDirErr) {
+		recorder := phaseRecorderFromContext(ctx)
+
 		if !cacheExists {
-			if err := ep.remoteFetchStrategy(); err != nil {
+			finishDownload := recorder.begin(PhaseDownload)
+			err := ep.remoteFetchStrategy(ctx)
+			finishDownload(err)
+
+			if err != nil {
 				return err
 			}
 		}
 
-		if err := decompressTarXz(defaultTarReader, cacheLocation, ep.config.binariesPath); err != nil {
+		if err := ep.createRuntimePath(); err != nil {
+			return err
+		}
+
+		finishExtract := recorder.begin(PhaseExtract)
+		err := decompressTarXz(ctx, defaultTarReader, cacheLocation, ep.config.binariesPath)
+		finishExtract(err)
+
+		if err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (ep *EmbeddedPostgres) cleanDataDirectoryAndInit() error {
+// removeDataDirectory deletes the data directory along with the WAL directory linked from it.
+func (ep *EmbeddedPostgres) removeDataDirectory() error {
+	if err := removeWALDir(ep.config.dataPath, ep.config.initDB.walDir); err != nil {
+		return err
+	}
+
 	if err := os.RemoveAll(ep.config.dataPath); err != nil {
-		return fmt.Errorf("unable to clean up data directory %s with error: %s", ep.config.dataPath, err)
+		return fmt.Errorf("unable to clean up data directory %s with error: %w", ep.config.dataPath, err)
+	}
+
+	return nil
+}
+
+// discardDataDirectory removes the data directory after Start failed with err before it was fully set up, returning err
+// along with any error removing it.
+func (ep *EmbeddedPostgres) discardDataDirectory(err error) error {
+	if removeErr := ep.removeDataDirectory(); removeErr != nil {
+		return fmt.Errorf("unable to remove data directory with error %v caused by error %w", removeErr, err)
 	}
 
-	if err := ep.initDatabase(ep.config.binariesPath, ep.config.runtimePath, ep.config.dataPath, ep.config.username, ep.config.password, ep.config.locale, ep.config.encoding, ep.syncedLogger.file); err != nil {
+	return err
+}
+
+func (ep *EmbeddedPostgres) cleanDataDirectoryAndInit(ctx context.Context) error {
+	if err := ep.removeDataDirectory(); err != nil {
+		return err
+	}
+
+	walDir := ep.config.initDB.walDir
+
+	if ep.runAs != nil {
+		// initdb may not be allowed to create the data directory itself when it is outside the runtime directory
+		for _, dir := range []string{ep.config.dataPath, walDir} {
+			if dir == "" {
+				continue
+			}
+
+			if err := os.MkdirAll(dir, 0700); err != nil {
+				return fmt.Errorf("unable to create data directory %s with error: %w", dir, err)
+			}
+
+			if err := ep.runAs.chownAll(dir); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := ep.initDatabase(ctx, ep.config.binariesPath, ep.config.runtimePath, ep.config.dataPath, ep.config.username, ep.config.password, ep.config.locale, ep.config.encoding, ep.syncedLogger.file, ep.runAs, ep.config.initDB); err != nil {
 		return err
 	}
 
@@ -181,23 +424,177 @@ func (ep *EmbeddedPostgres) cleanDataDirectoryAndInit() error {
 
 // Stop will try to stop the Postgres process gracefully returning an error when there were any problems.
 func (ep *EmbeddedPostgres) Stop() error {
-	if !ep.started {
-		return ErrServerNotStarted
+	return ep.StopContext(context.Background())
+}
+
+// StopContext behaves like Stop but will abort waiting for the Postgres process to shut down when ctx is done.
+// When Start is still in progress it is aborted instead and StopContext returns once it has cleaned up. While the
+// AfterStart hook runs it returns ErrServerStarting without waiting, as it may be called from the hook, and Start stops
+// Postgres and returns the context error once the hook returns.
+func (ep *EmbeddedPostgres) StopContext(ctx context.Context) error {
+	aborted, err := ep.abortStart()
+	if err != nil {
+		return err
 	}
 
-	if err := stopPostgres(ep); err != nil {
+	if aborted {
+		// a Start that failed after being aborted has nothing left to stop
+		if err := ep.beginTransition(StateStopped, nil, StateFailed); err == nil {
+			return nil
+		}
+	}
+
+	if err := ep.beginTransition(StateStopping, nil, StateRunning); err != nil {
 		return err
 	}
 
-	ep.started = false
+	ep.lifecycle.Lock()
+	defer ep.lifecycle.Unlock()
+
+	if ep.postmaster != nil && ep.postmaster.exited() {
+		ep.endTransition(StateStopped)
+
+		_ = ep.syncedLogger.flush()
+		_ = ep.removePrivateSocketDirectory()
+
+		return ep.Wait()
+	}
+
+	if ep.attached && ep.config.attachMode == AttachAndDetach {
+		ep.endTransition(StateStopped)
+		closeIfOpen(ep.done)
+
+		if err := ep.syncedLogger.flush(); err != nil {
+			return err
+		}
+
+		return ep.syncedLogger.remove()
+	}
+
+	// Postgres is stopped even when the hook fails, so that the failure does not leave the server running
+	hookErr := ep.runHook(ctx, "BeforeStop", ep.config.beforeStop, true)
+
+	stopErr := stopPostgres(ctx, ep)
+	if stopErr != nil && !isStopTimeout(stopErr) {
+		ep.endTransition(StateRunning)
+		return stopErr
+	}
+
+	ep.endTransition(StateStopped)
 
 	if err := ep.syncedLogger.flush(); err != nil {
 		return err
 	}
 
+	if err := ep.removePrivateSocketDirectory(); err != nil {
+		return err
+	}
+
+	if hookErr != nil {
+		return hookErr
+	}
+
+	if stopErr != nil {
+		return stopErr
+	}
+
+	return ep.cleanUpAfterStop()
+}
+
+// cleanUpAfterStop deletes the log of a successfully stopped instance, and its runtime and data directories when
+// CleanupOnStop is set. The log is kept whenever Stop fails so that the failure can be investigated.
+func (ep *EmbeddedPostgres) cleanUpAfterStop() error {
+	if err := ep.syncedLogger.remove(); err != nil {
+		return err
+	}
+
+	if !ep.config.cleanupOnStop {
+		return nil
+	}
+
+	if err := ep.removeDataDirectory(); err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(ep.config.runtimePath); err != nil {
+		return fmt.Errorf("unable to clean up runtime directory %s with error: %w", ep.config.runtimePath, err)
+	}
+
 	return nil
 }
 
+// Serve starts the Postgres process, blocks until ctx is done and then stops the process again.
+// It returns any error raised while starting or stopping, but not the error of ctx itself.
+// When running Supervised, Serve also returns early with an error if the process exits unexpectedly. A Restart while
+// Serve is running does not end it.
+func (ep *EmbeddedPostgres) Serve(ctx context.Context) error {
+	if err := ep.StartContext(ctx); err != nil {
+		return err
+	}
+
+	for {
+		done := ep.Done()
+
+		select {
+		case <-ctx.Done():
+			return ep.StopContext(context.Background())
+		case <-done:
+		}
+
+		// Restart closes the done channel of the process it stops and holds the lifecycle lock until the new process
+		// is running with a done channel of its own
+		ep.lifecycle.Lock()
+		restarted := ep.State() == StateRunning && ep.Done() != done
+		ep.lifecycle.Unlock()
+
+		if !restarted {
+			// the supervised process exited on its own, Stop reports why
+			return ep.StopContext(context.Background())
+		}
+	}
+}
+
+// Port returns the port Postgres listens on. When configured with a port of 0 this is the free port chosen by Start.
+func (ep *EmbeddedPostgres) Port() uint32 {
+	ep.stateMu.Lock()
+	defer ep.stateMu.Unlock()
+
+	return ep.config.port
+}
+
+// GetConnectionURL returns the connection URL of the Postgres process, including the port chosen by Start.
+func (ep *EmbeddedPostgres) GetConnectionURL() string {
+	ep.stateMu.Lock()
+	defer ep.stateMu.Unlock()
+
+	return ep.config.GetConnectionURL()
+}
+
+// Config returns the configuration in effect, including the paths and port chosen by Start. Before Start it returns the
+// configuration the instance was created with.
+func (ep *EmbeddedPostgres) Config() Config {
+	ep.stateMu.Lock()
+	defer ep.stateMu.Unlock()
+
+	config := ep.config
+	config.startParameters = config.GetStartParameters()
+
+	return config
+}
+
+// LogFile returns the path of the file Postgres writes its log to, or an empty string before Start.
+// The file is deleted when Stop succeeds and kept when Start or Stop fail.
+func (ep *EmbeddedPostgres) LogFile() string {
+	ep.stateMu.Lock()
+	defer ep.stateMu.Unlock()
+
+	if ep.syncedLogger == nil {
+		return ""
+	}
+
+	return ep.syncedLogger.file.Name()
+}
+
 func encodeOptions(port uint32, parameters map[string]string) string {
 	options := []string{fmt.Sprintf("-p %d", port)}
 	for k, v := range parameters {
@@ -209,60 +606,95 @@ func encodeOptions(port uint32, parameters map[string]string) string {
 	return strings.Join(options, " ")
 }
 
-func startPostgres(ep *EmbeddedPostgres) error {
-	postgresBinary := filepath.Join(ep.config.binariesPath, "bin/pg_ctl")
-	postgresProcess := exec.Command(postgresBinary, "start", "-w",
-		"-D", ep.config.dataPath,
-		"-o", encodeOptions(ep.config.port, ep.config.startParameters))
-	postgresProcess.Stdout = ep.syncedLogger.file
-	postgresProcess.Stderr = ep.syncedLogger.file
+// startProcess starts Postgres on the already initialised data directory, either supervised or through pg_ctl.
+func (ep *EmbeddedPostgres) startProcess(ctx context.Context) error {
+	if ep.config.supervised || ep.config.stopOnProcessExit {
+		postmaster, err := startSupervisedPostgres(ctx, ep)
+		if err != nil {
+			return err
+		}
 
-	if err := postgresProcess.Run(); err != nil {
-		_ = ep.syncedLogger.flush()
-		logContent, _ := readLogsOrTimeout(ep.syncedLogger.file)
+		ep.stateMu.Lock()
+		ep.postmaster = postmaster
+		ep.done = postmaster.done
+		ep.stateMu.Unlock()
 
-		return fmt.Errorf("could not start postgres using %s:\n%s", postgresProcess.String(), string(logContent))
+		return nil
 	}
 
+	if err := startPostgres(ctx, ep); err != nil {
+		return err
+	}
+
+	ep.stateMu.Lock()
+	ep.postmaster = nil
+	ep.done = make(chan struct{})
+	ep.stateMu.Unlock()
+
 	return nil
 }
 
-func stopPostgres(ep *EmbeddedPostgres) error {
+func startPostgres(ctx context.Context, ep *EmbeddedPostgres) error {
 	postgresBinary := filepath.Join(ep.config.binariesPath, "bin/pg_ctl")
-	postgresProcess := exec.Command(postgresBinary, "stop", "-w",
-		"-D", ep.config.dataPath)
-	postgresProcess.Stderr = ep.syncedLogger.file
+	postgresProcess := exec.CommandContext(ctx, postgresBinary, "start", "-w",
+		"-D", ep.config.dataPath,
+		"-o", encodeOptions(ep.config.port, ep.config.serverParameters()))
 	postgresProcess.Stdout = ep.syncedLogger.file
+	postgresProcess.Stderr = ep.syncedLogger.file
+	ep.runAs.apply(postgresProcess)
 
 	if err := postgresProcess.Run(); err != nil {
-		return err
+		if ctx.Err() != nil {
+			// pg_ctl was killed part way through, the server may still come up without anyone to stop it
+			_ = stopPostgres(context.Background(), ep)
+
+			return ctx.Err()
+		}
+
+		_ = ep.syncedLogger.flush()
+		logContent, _ := readLogsOrTimeout(ep.syncedLogger.file)
+
+		return &StartError{Command: postgresProcess.String(), Err: err, Log: string(logContent)}
 	}
 
 	return nil
 }
 
-func ensurePortAvailable(port uint32) error {
-	conn, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", port))
-	if err != nil {
-		return fmt.Errorf("process already listening on port %d", port)
+func stopPostgres(ctx context.Context, ep *EmbeddedPostgres) error {
+	if ep.postmaster != nil {
+		ep.postmaster.expectExit()
 	}
 
-	if err := conn.Close(); err != nil {
+	var err error
+	if ep.config.stopTimeout > 0 {
+		err = stopPostgresOrEscalate(ctx, ep)
+	} else {
+		err = pgCtlStop(ctx, ep, ep.config.shutdownMode, 0)
+	}
+
+	if err != nil && !isStopTimeout(err) {
 		return err
 	}
 
-	return nil
+	if ep.postmaster != nil {
+		<-ep.postmaster.done
+	} else if ep.done != nil {
+		closeIfOpen(ep.done)
+	}
+
+	return err
 }
 
-func dataDirIsValid(dataDir string, version PostgresVersion) bool {
-	pgVersion := filepath.Join(dataDir, "PG_VERSION")
+// isStopTimeout reports whether err was returned after the server was stopped forcefully, as opposed to not at all.
+func isStopTimeout(err error) bool {
+	var stopTimeoutErr *StopTimeoutError
+	return errors.As(err, &stopTimeoutErr)
+}
 
-	d, err := os.ReadFile(pgVersion)
-	if err != nil {
-		return false
+func closeIfOpen(done chan struct{}) {
+	select {
+	case <-done:
+	default:
+		close(done)
 	}
-
-	v := strings.TrimSuffix(string(d), "\n")
-
-	return strings.HasPrefix(string(version), v)
 }
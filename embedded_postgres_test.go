@@ -1,6 +1,7 @@
 package embeddedpostgres
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -69,7 +70,7 @@ func Test_ErrorWhenRemoteFetchError(t *testing.T) {
 	database.cacheLocator = func() (string, bool) {
 		return "", false
 	}
-	database.remoteFetchStrategy = func() error {
+	database.remoteFetchStrategy = func(ctx context.Context) error {
 		return errors.New("did not work")
 	}
 
@@ -78,6 +79,25 @@ func Test_ErrorWhenRemoteFetchError(t *testing.T) {
 	assert.EqualError(t, err, "did not work")
 }
 
+func Test_StartContext_ErrorWhenContextCancelled(t *testing.T) {
+	database := NewDatabase()
+	database.cacheLocator = func() (string, bool) {
+		return "", false
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	database.remoteFetchStrategy = func(ctx context.Context) error {
+		cancel()
+		<-ctx.Done()
+
+		return ctx.Err()
+	}
+
+	err := database.StartContext(ctx)
+
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
 func Test_ErrorWhenUnableToUnArchiveFile_WrongFormat(t *testing.T) {
 	jarFile, cleanUp := createTempZipArchive()
 	defer cleanUp()
@@ -103,6 +123,39 @@ func Test_ErrorWhenUnableToUnArchiveFile_WrongFormat(t *testing.T) {
 	assert.EqualError(t, err, fmt.Sprintf(`unable to extract postgres archive %s to %s, if running parallel tests, configure RuntimePath to isolate testing directories, xz: file format not recognized`, jarFile, filepath.Join(filepath.Dir(jarFile), "extracted")))
 }
 
+func Test_Config_ReportsPathsChosenByStart(t *testing.T) {
+	jarFile, cleanUp := createTempZipArchive()
+	defer cleanUp()
+
+	database := NewDatabase(DefaultConfig().
+		Version(V14).
+		StartParameters(map[string]string{"max_connections": "200"}))
+
+	database.cacheLocator = func() (string, bool) {
+		return jarFile, true
+	}
+
+	assert.Empty(t, database.Config().GetRuntimePath())
+	assert.Empty(t, database.LogFile())
+
+	assert.Error(t, database.Start())
+
+	config := database.Config()
+	runtimePath := filepath.Join(filepath.Dir(jarFile), "extracted")
+
+	assert.Equal(t, runtimePath, config.GetRuntimePath())
+	assert.Equal(t, filepath.Join(runtimePath, "data"), config.GetDataPath())
+	assert.Equal(t, runtimePath, config.GetBinariesPath())
+	assert.Equal(t, V14, config.GetVersion())
+	assert.Equal(t, uint32(5432), config.GetPort())
+	assert.FileExists(t, database.LogFile())
+
+	parameters := config.GetStartParameters()
+	parameters["max_connections"] = "1"
+
+	assert.Equal(t, map[string]string{"max_connections": "200"}, database.Config().GetStartParameters())
+}
+
 func Test_ErrorWhenUnableToInitDatabase(t *testing.T) {
 	jarFile, cleanUp := createTempXzArchive()
 	defer cleanUp()
@@ -123,7 +176,7 @@ func Test_ErrorWhenUnableToInitDatabase(t *testing.T) {
 		return jarFile, true
 	}
 
-	database.initDatabase = func(binaryExtractLocation, runtimePath, dataLocation, username, password, locale string, encoding string, logger *os.File) error {
+	database.initDatabase = func(ctx context.Context, binaryExtractLocation, runtimePath, dataLocation, username, password, locale string, encoding string, logger *os.File, runAs *osUser, options initDBOptions) error {
 		return errors.New("ah it did not work")
 	}
 
@@ -156,7 +209,7 @@ func Test_ErrorWhenUnableToCreateDatabase(t *testing.T) {
 		RuntimePath(extractPath).
 		StartTimeout(10 * time.Second))
 
-	database.createDatabase = func(port uint32, username, password, database string) error {
+	database.createDatabase = func(ctx context.Context, host string, port uint32, username, password, database string) error {
 		return errors.New("ah noes")
 	}
 
@@ -176,7 +229,7 @@ func Test_TimesOutWhenCannotStart(t *testing.T) {
 		Database("something-fancy").
 		StartTimeout(500 * time.Millisecond))
 
-	database.createDatabase = func(port uint32, username, password, database string) error {
+	database.createDatabase = func(ctx context.Context, host string, port uint32, username, password, database string) error {
 		return nil
 	}
 
@@ -193,6 +246,48 @@ func Test_ErrorWhenStopCalledBeforeStart(t *testing.T) {
 	assert.ErrorIs(t, err, ErrServerNotStarted)
 }
 
+func Test_Stop_RemovesLogAndCleansUp(t *testing.T) {
+	var calls []string
+
+	database := fakeBinariesDatabase(t, DefaultConfig().
+		CleanupOnStop(true), "0", &calls)
+	database.config.dataPath = filepath.Join(database.config.runtimePath, "data")
+	require.NoError(t, os.MkdirAll(database.config.dataPath, os.ModePerm))
+
+	logger, err := newSyncedLogger(database.config.runtimePath, nil)
+	require.NoError(t, err)
+
+	database.syncedLogger = logger
+	database.done = make(chan struct{})
+	database.state = StateRunning
+
+	assert.NoError(t, database.Stop())
+	assert.NoFileExists(t, logger.file.Name())
+	assert.NoDirExists(t, database.config.runtimePath)
+	assert.DirExists(t, database.config.binariesPath)
+}
+
+func Test_Stop_KeepsLogOnFailure(t *testing.T) {
+	var calls []string
+
+	database := fakeBinariesDatabase(t, DefaultConfig().
+		CleanupOnStop(true), "1", &calls)
+	database.config.dataPath = filepath.Join(database.config.runtimePath, "data")
+	require.NoError(t, os.MkdirAll(database.config.dataPath, os.ModePerm))
+
+	logger, err := newSyncedLogger(database.config.runtimePath, nil)
+	require.NoError(t, err)
+
+	database.syncedLogger = logger
+	database.done = make(chan struct{})
+	database.state = StateRunning
+
+	assert.Error(t, database.Stop())
+	assert.Equal(t, logger.file.Name(), database.LogFile())
+	assert.FileExists(t, database.LogFile())
+	assert.DirExists(t, database.config.dataPath)
+}
+
 func Test_ErrorWhenStartCalledWhenAlreadyStarted(t *testing.T) {
 	database := NewDatabase()
 
@@ -226,7 +321,7 @@ func Test_ErrorWhenCannotStartPostgresProcess(t *testing.T) {
 		return jarFile, true
 	}
 
-	database.initDatabase = func(binaryExtractLocation, runtimePath, dataLocation, username, password, locale string, encoding string, logger *os.File) error {
+	database.initDatabase = func(ctx context.Context, binaryExtractLocation, runtimePath, dataLocation, username, password, locale string, encoding string, logger *os.File, runAs *osUser, options initDBOptions) error {
 		_, _ = logger.Write([]byte("ah it did not work"))
 		return nil
 	}
@@ -478,6 +573,103 @@ func Test_CustomStartParameters(t *testing.T) {
 	}
 }
 
+func Test_Serve(t *testing.T) {
+	database := NewDatabase()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+
+	go func() {
+		served <- database.Serve(ctx)
+	}()
+
+	connected := assert.Eventually(t, func() bool {
+		db, err := sql.Open("postgres", "host=localhost port=5432 user=postgres password=postgres dbname=postgres sslmode=disable")
+		if err != nil {
+			return false
+		}
+
+		defer db.Close()
+
+		return db.Ping() == nil
+	}, 60*time.Second, 100*time.Millisecond)
+
+	cancel()
+
+	assert.NoError(t, <-served)
+	assert.True(t, connected)
+	assert.Equal(t, StateStopped, database.State())
+}
+
+func Test_Serve_KeepsRunningAcrossRestart(t *testing.T) {
+	database, _ := fixtureDatabase(t, DefaultConfig().
+		UnixSocketDirectory(t.TempDir()).
+		TCP(false).
+		Port(9853), map[string]string{"pg_ctl": exitScript("0")})
+	database.initDatabase = func(ctx context.Context, binaryExtractLocation, runtimePath, pgDataDir, username, password, locale string, encoding string, logger *os.File, runAs *osUser, options initDBOptions) error {
+		return nil
+	}
+
+	fakePostgresServer(t, database.config.unixSocketDirectory, 9853)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+
+	go func() {
+		served <- database.Serve(ctx)
+	}()
+
+	require.Eventually(t, func() bool {
+		return database.State() == StateRunning
+	}, 10*time.Second, 10*time.Millisecond)
+
+	_, err := database.Restart(nil)
+	require.NoError(t, err)
+
+	select {
+	case err := <-served:
+		t.Fatalf("Serve returned after Restart with %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	assert.Equal(t, StateRunning, database.State())
+
+	cancel()
+
+	assert.NoError(t, <-served)
+	assert.Equal(t, StateStopped, database.State())
+}
+
+func Test_FreePort(t *testing.T) {
+	database := NewDatabase(DefaultConfig().
+		Port(0).
+		PortRange(9900, 9950))
+
+	if err := database.Start(); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	assert.GreaterOrEqual(t, database.Port(), uint32(9900))
+	assert.LessOrEqual(t, database.Port(), uint32(9950))
+
+	db, err := sql.Open("postgres", database.GetConnectionURL()+"?sslmode=disable")
+	if err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	if err = db.Ping(); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	if err := db.Close(); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	if err := database.Stop(); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+}
+
 func Test_CanStartAndStopTwice(t *testing.T) {
 	database := NewDatabase()
 
@@ -767,12 +959,12 @@ func Test_PrefetchedBinaries(t *testing.T) {
 		RuntimePath(runtimeTempDir))
 
 	// Download and unarchive postgres into the bindir.
-	if err := database.remoteFetchStrategy(); err != nil {
+	if err := database.remoteFetchStrategy(context.Background()); err != nil {
 		panic(err)
 	}
 
 	cacheLocation, _ := database.cacheLocator()
-	if err := decompressTarXz(defaultTarReader, cacheLocation, binTempDir); err != nil {
+	if err := decompressTarXz(context.Background(), defaultTarReader, cacheLocation, binTempDir); err != nil {
 		panic(err)
 	}
 
@@ -780,7 +972,7 @@ func Test_PrefetchedBinaries(t *testing.T) {
 	database.cacheLocator = func() (string, bool) {
 		return "", false
 	}
-	database.remoteFetchStrategy = func() error {
+	database.remoteFetchStrategy = func(ctx context.Context) error {
 		return errors.New("did not work")
 	}
 
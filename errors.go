@@ -0,0 +1,365 @@
+package embeddedpostgres
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Sentinel errors for each failure mode, errors returned by EmbeddedPostgres match them with errors.Is. The error
+// types below carry the details of a failure and can be retrieved with errors.As.
+var (
+	ErrPortInUse            = errors.New("port is in use")
+	ErrVersionNotFound      = errors.New("version not found")
+	ErrDownloadFailed       = errors.New("download failed")
+	ErrChecksumMismatch     = errors.New("checksum mismatch")
+	ErrExtractFailed        = errors.New("extract failed")
+	ErrInitDBFailed         = errors.New("initdb failed")
+	ErrStartFailed          = errors.New("start failed")
+	ErrStartTimeout         = errors.New("start timed out")
+	ErrCreateDatabaseFailed = errors.New("create database failed")
+	ErrDataDirectoryLocked  = errors.New("data directory is locked")
+	ErrStopTimeout          = errors.New("stop timed out")
+	ErrUnexpectedExit       = errors.New("postgres exited unexpectedly")
+	ErrHookFailed           = errors.New("hook failed")
+	ErrRuntimePathNotOwned  = errors.New("runtime path not created by embedded-postgres")
+	ErrDataVersionMismatch  = errors.New("data directory version mismatch")
+	ErrAttachFailed         = errors.New("unable to attach to running postgres")
+	ErrCreateRoleFailed     = errors.New("unable to create role")
+	ErrInitScriptFailed     = errors.New("init script failed")
+	ErrExecFailed           = errors.New("psql failed")
+
+	// ErrInvalidPortRange, ErrTCPWithoutUnixSocket and ErrUnknownDataPathPolicy report a Config that Start cannot use.
+	ErrInvalidPortRange      = errors.New("invalid port range")
+	ErrTCPWithoutUnixSocket  = errors.New("TCP can only be disabled when listening on a Unix socket")
+	ErrUnknownDataPathPolicy = errors.New("unknown data path policy")
+)
+
+// PortInUseError is returned when the configured port, or every port of the configured range, is either listened on
+// or reserved by another embedded Postgres process.
+type PortInUseError struct {
+	Port uint32
+	// PortRangeStart and PortRangeEnd are set instead of Port when no port of the PortRange was free.
+	PortRangeStart uint32
+	PortRangeEnd   uint32
+	// Reserved is true when the port is reserved by another process that is starting embedded Postgres.
+	Reserved bool
+	Err      error
+}
+
+func (e *PortInUseError) Error() string {
+	switch {
+	case e.PortRangeEnd != 0:
+		return fmt.Sprintf("no free port available in range %d-%d", e.PortRangeStart, e.PortRangeEnd)
+	case e.Reserved:
+		return fmt.Sprintf("port %d is reserved by another process", e.Port)
+	default:
+		return fmt.Sprintf("process already listening on port %d", e.Port)
+	}
+}
+
+func (e *PortInUseError) Unwrap() error { return e.Err }
+
+func (e *PortInUseError) Is(target error) bool { return target == ErrPortInUse }
+
+// VersionNotFoundError is returned when the binary repository has no binaries for the configured version.
+type VersionNotFoundError struct {
+	Version PostgresVersion
+	URL     string
+}
+
+func (e *VersionNotFoundError) Error() string {
+	return fmt.Sprintf("no version found matching %s", e.Version)
+}
+
+func (e *VersionNotFoundError) Is(target error) bool { return target == ErrVersionNotFound }
+
+// DownloadError is returned when the binaries cannot be downloaded from URL, or the download is not a valid archive.
+type DownloadError struct {
+	URL string
+	Err error
+}
+
+func (e *DownloadError) Error() string {
+	return fmt.Sprintf("error fetching postgres from %s: %s", e.URL, e.Err)
+}
+
+func (e *DownloadError) Unwrap() error { return e.Err }
+
+func (e *DownloadError) Is(target error) bool { return target == ErrDownloadFailed }
+
+// ChecksumMismatchError is returned when the downloaded binaries do not match the published sha256 checksum.
+type ChecksumMismatchError struct {
+	URL      string
+	Expected string
+	Actual   string
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	return "downloaded checksums do not match"
+}
+
+func (e *ChecksumMismatchError) Is(target error) bool { return target == ErrChecksumMismatch }
+
+// ExtractError is returned when the binaries archive cannot be extracted. Archive and Path are set when known.
+type ExtractError struct {
+	Archive string
+	Path    string
+	Err     error
+}
+
+func (e *ExtractError) Error() string {
+	if e.Archive == "" {
+		return fmt.Sprintf("unable to extract postgres archive: %s", e.Err)
+	}
+
+	return fmt.Sprintf("unable to extract postgres archive %s to %s, if running parallel tests, configure RuntimePath to isolate testing directories, %s",
+		e.Archive,
+		e.Path,
+		e.Err)
+}
+
+func (e *ExtractError) Unwrap() error { return e.Err }
+
+func (e *ExtractError) Is(target error) bool { return target == ErrExtractFailed }
+
+// InitDBError is returned when initdb fails, Log holds the output of initdb.
+type InitDBError struct {
+	Command string
+	Err     error
+	Log     string
+}
+
+func (e *InitDBError) Error() string {
+	return fmt.Sprintf("unable to init database using '%s': %s\n%s", e.Command, e.Err, e.Log)
+}
+
+func (e *InitDBError) Unwrap() error { return e.Err }
+
+func (e *InitDBError) Is(target error) bool { return target == ErrInitDBFailed }
+
+// StartError is returned when the Postgres process cannot be started, Log holds the server log when available.
+type StartError struct {
+	Command string
+	Err     error
+	Log     string
+}
+
+func (e *StartError) Error() string {
+	if e.Log == "" && e.Err != nil {
+		return fmt.Sprintf("could not start postgres using %s: %s", e.Command, e.Err)
+	}
+
+	return fmt.Sprintf("could not start postgres using %s:\n%s", e.Command, e.Log)
+}
+
+func (e *StartError) Unwrap() error { return e.Err }
+
+func (e *StartError) Is(target error) bool { return target == ErrStartFailed }
+
+// StartTimeoutError is returned when Postgres does not accept connections within the configured StartTimeout.
+type StartTimeoutError struct {
+	Timeout time.Duration
+	Port    uint32
+	// ConnectionURL is the URL of the configured database that did not accept connections.
+	ConnectionURL string
+	// Log is the end of the server log, which usually tells why Postgres did not accept connections.
+	Log string
+}
+
+func (e *StartTimeoutError) Error() string {
+	if e.Log == "" {
+		return "timed out waiting for database to become available"
+	}
+
+	return "timed out waiting for database to become available\n" + e.Log
+}
+
+func (e *StartTimeoutError) Is(target error) bool { return target == ErrStartTimeout }
+
+// CreateDatabaseError is returned when the configured database cannot be created.
+type CreateDatabaseError struct {
+	Database string
+	Err      error
+}
+
+func (e *CreateDatabaseError) Error() string {
+	return fmt.Sprintf("unable to connect to create database with custom name %s with the following error: %s", e.Database, e.Err)
+}
+
+func (e *CreateDatabaseError) Unwrap() error { return e.Err }
+
+func (e *CreateDatabaseError) Is(target error) bool { return target == ErrCreateDatabaseFailed }
+
+// DataDirectoryLockedError is returned when the data directory is in use by a running process that cannot safely be
+// stopped, either because it was not started by embedded-postgres or because it is not known to be orphaned.
+type DataDirectoryLockedError struct {
+	DataPath   string
+	PID        int
+	Executable string
+	// Embedded is true when the process was started from the configured binaries.
+	Embedded bool
+	// OwnerPID is the Go process that started the process, zero when unknown.
+	OwnerPID int
+}
+
+func (e *DataDirectoryLockedError) Error() string {
+	reason := "which was not started by embedded-postgres"
+
+	if e.Embedded && e.OwnerPID != 0 {
+		reason = fmt.Sprintf("which belongs to embedded-postgres in the running process %d", e.OwnerPID)
+	} else if e.Embedded {
+		reason = "which was started by embedded-postgres in an unknown process"
+	}
+
+	return fmt.Sprintf("data directory %s is locked by process %d (%s) %s", e.DataPath, e.PID, e.Executable, reason)
+}
+
+func (e *DataDirectoryLockedError) Is(target error) bool { return target == ErrDataDirectoryLocked }
+
+// UnexpectedExitError is returned by Wait and Stop when a Supervised process exited without Stop being called.
+type UnexpectedExitError struct {
+	ExitCode int
+	Err      error
+	Log      string
+}
+
+func (e *UnexpectedExitError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("postgres exited unexpectedly:\n%s", e.Log)
+	}
+
+	return fmt.Sprintf("postgres exited unexpectedly: %s\n%s", e.Err, e.Log)
+}
+
+func (e *UnexpectedExitError) Unwrap() error { return e.Err }
+
+func (e *UnexpectedExitError) Is(target error) bool { return target == ErrUnexpectedExit }
+
+// HookError is returned when a lifecycle Hook fails, Hook names the failing hook such as "AfterStart".
+type HookError struct {
+	Hook string
+	Err  error
+}
+
+func (e *HookError) Error() string {
+	return fmt.Sprintf("%s hook failed: %s", e.Hook, e.Err)
+}
+
+func (e *HookError) Unwrap() error { return e.Err }
+
+func (e *HookError) Is(target error) bool { return target == ErrHookFailed }
+
+// RuntimePathNotOwnedError is returned when the RuntimePath is a non-empty directory that was not created by
+// embedded-postgres, which Start refuses to delete unless ForceRuntimePathCleanup is set.
+type RuntimePathNotOwnedError struct {
+	Path string
+}
+
+func (e *RuntimePathNotOwnedError) Error() string {
+	return fmt.Sprintf("refusing to delete runtime directory %s as it is not empty and was not created by embedded-postgres, set ForceRuntimePathCleanup to delete it anyway",
+		e.Path)
+}
+
+func (e *RuntimePathNotOwnedError) Is(target error) bool { return target == ErrRuntimePathNotOwned }
+
+// DataVersionMismatchError is returned when the DataPath was initialised by a different major version of Postgres and
+// the DataPathFail policy is configured.
+type DataVersionMismatchError struct {
+	DataPath string
+	Found    string
+	Expected string
+}
+
+func (e *DataVersionMismatchError) Error() string {
+	return fmt.Sprintf("data directory %s was initialised by Postgres %s but version %s is configured",
+		e.DataPath, e.Found, e.Expected)
+}
+
+func (e *DataVersionMismatchError) Is(target error) bool { return target == ErrDataVersionMismatch }
+
+// AttachError is returned when a Postgres server is running on the DataPath but Start cannot attach to it, typically
+// because it does not accept the configured credentials.
+type AttachError struct {
+	DataPath string
+	Port     uint32
+	Err      error
+}
+
+func (e *AttachError) Error() string {
+	return fmt.Sprintf("unable to attach to postgres running on data directory %s and port %d: %s", e.DataPath, e.Port, e.Err)
+}
+
+func (e *AttachError) Unwrap() error { return e.Err }
+
+func (e *AttachError) Is(target error) bool { return target == ErrAttachFailed }
+
+// CreateRoleError is returned when one of the configured roles cannot be created or granted its privileges.
+type CreateRoleError struct {
+	Role string
+	Err  error
+}
+
+func (e *CreateRoleError) Error() string {
+	return fmt.Sprintf("unable to create role %s with error: %s", e.Role, e.Err)
+}
+
+func (e *CreateRoleError) Unwrap() error { return e.Err }
+
+func (e *CreateRoleError) Is(target error) bool { return target == ErrCreateRoleFailed }
+
+// InitScriptError is returned when one of the files of the InitScriptsDir fails. Line is the line psql reported the
+// error in, or zero when unknown.
+type InitScriptError struct {
+	File   string
+	Line   int
+	Err    error
+	Output string
+}
+
+func (e *InitScriptError) Error() string {
+	location := e.File
+	if e.Line > 0 {
+		location = fmt.Sprintf("%s:%d", e.File, e.Line)
+	}
+
+	if e.Output == "" {
+		return fmt.Sprintf("init script %s failed: %s", location, e.Err)
+	}
+
+	return fmt.Sprintf("init script %s failed: %s\n%s", location, e.Err, e.Output)
+}
+
+func (e *InitScriptError) Unwrap() error { return e.Err }
+
+func (e *InitScriptError) Is(target error) bool { return target == ErrInitScriptFailed }
+
+// ExecError is returned when psql run by ExecFile or ExecSQL fails. File is empty for ExecSQL and Line is the line psql
+// reported the error in, or zero when unknown.
+type ExecError struct {
+	File   string
+	Line   int
+	Err    error
+	Stderr string
+}
+
+func (e *ExecError) Error() string {
+	location := e.File
+	if location == "" {
+		location = "SQL"
+	}
+
+	if e.Line > 0 {
+		location = fmt.Sprintf("%s:%d", location, e.Line)
+	}
+
+	if e.Stderr == "" {
+		return fmt.Sprintf("psql failed running %s: %s", location, e.Err)
+	}
+
+	return fmt.Sprintf("psql failed running %s: %s\n%s", location, e.Err, e.Stderr)
+}
+
+func (e *ExecError) Unwrap() error { return e.Err }
+
+func (e *ExecError) Is(target error) bool { return target == ErrExecFailed }
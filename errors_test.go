@@ -0,0 +1,60 @@
+package embeddedpostgres
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Errors_MatchSentinels(t *testing.T) {
+	cause := errors.New("the cause")
+
+	for _, tc := range []struct {
+		err      error
+		sentinel error
+		message  string
+		cause    bool
+	}{
+		{&PortInUseError{Port: 5432, Err: cause}, ErrPortInUse, "process already listening on port 5432", true},
+		{&PortInUseError{Port: 5432, Reserved: true, Err: cause}, ErrPortInUse, "port 5432 is reserved by another process", true},
+		{&PortInUseError{PortRangeStart: 5432, PortRangeEnd: 5440}, ErrPortInUse, "no free port available in range 5432-5440", false},
+		{&VersionNotFoundError{Version: V16, URL: "https://repo"}, ErrVersionNotFound, "no version found matching 16.9.0", false},
+		{&DownloadError{URL: "https://repo/pg.jar", Err: cause}, ErrDownloadFailed, "error fetching postgres from https://repo/pg.jar: the cause", true},
+		{&ChecksumMismatchError{URL: "https://repo/pg.jar", Expected: "a", Actual: "b"}, ErrChecksumMismatch, "downloaded checksums do not match", false},
+		{&ExtractError{Err: cause}, ErrExtractFailed, "unable to extract postgres archive: the cause", true},
+		{&InitDBError{Command: "initdb", Err: cause, Log: "the log"}, ErrInitDBFailed, "unable to init database using 'initdb': the cause\nthe log", true},
+		{&StartError{Command: "pg_ctl start", Err: cause, Log: "the log"}, ErrStartFailed, "could not start postgres using pg_ctl start:\nthe log", true},
+		{&StartError{Command: "postgres", Err: cause}, ErrStartFailed, "could not start postgres using postgres: the cause", true},
+		{&StartTimeoutError{Timeout: time.Second, Port: 5432}, ErrStartTimeout, "timed out waiting for database to become available", false},
+		{&StartTimeoutError{Timeout: time.Second, Port: 5432, Log: "the log"}, ErrStartTimeout, "timed out waiting for database to become available\nthe log", false},
+		{&CreateDatabaseError{Database: "beer", Err: cause}, ErrCreateDatabaseFailed, "unable to connect to create database with custom name beer with the following error: the cause", true},
+		{&DataDirectoryLockedError{DataPath: "/data", PID: 42, Executable: "/bin/sleep"}, ErrDataDirectoryLocked, "data directory /data is locked by process 42 (/bin/sleep) which was not started by embedded-postgres", false},
+		{&DataDirectoryLockedError{DataPath: "/data", PID: 42, Executable: "/pg/bin/postgres", Embedded: true, OwnerPID: 7}, ErrDataDirectoryLocked, "data directory /data is locked by process 42 (/pg/bin/postgres) which belongs to embedded-postgres in the running process 7", false},
+		{&DataDirectoryLockedError{DataPath: "/data", PID: 42, Executable: "/pg/bin/postgres", Embedded: true}, ErrDataDirectoryLocked, "data directory /data is locked by process 42 (/pg/bin/postgres) which was started by embedded-postgres in an unknown process", false},
+		{&StopTimeoutError{Timeout: time.Second, Mode: ShutdownSmart, EscalatedTo: ShutdownFast}, ErrStopTimeout, "postgres did not stop within 1s using smart shutdown and was stopped using fast instead, open connections: none", false},
+		{&UnexpectedExitError{ExitCode: 3, Err: cause, Log: "the log"}, ErrUnexpectedExit, "postgres exited unexpectedly: the cause\nthe log", true},
+		{&DataVersionMismatchError{DataPath: "/data", Found: "15", Expected: "16"}, ErrDataVersionMismatch, "data directory /data was initialised by Postgres 15 but version 16 is configured", false},
+		{&AttachError{DataPath: "/data", Port: 5432, Err: cause}, ErrAttachFailed, "unable to attach to postgres running on data directory /data and port 5432: the cause", true},
+		{&CreateRoleError{Role: "app", Err: cause}, ErrCreateRoleFailed, "unable to create role app with error: the cause", true},
+		{&InitScriptError{File: "01.sql", Line: 3, Err: cause, Output: "the output"}, ErrInitScriptFailed, "init script 01.sql:3 failed: the cause\nthe output", true},
+		{&InitScriptError{File: "02.sh", Err: cause}, ErrInitScriptFailed, "init script 02.sh failed: the cause", true},
+		{&ExecError{File: "seed.sql", Line: 4, Err: cause, Stderr: "the stderr"}, ErrExecFailed, "psql failed running seed.sql:4: the cause\nthe stderr", true},
+		{&ExecError{Err: cause}, ErrExecFailed, "psql failed running SQL: the cause", true},
+		{&RuntimePathNotOwnedError{Path: "/tmp"}, ErrRuntimePathNotOwned, "refusing to delete runtime directory /tmp as it is not empty and was not created by embedded-postgres, set ForceRuntimePathCleanup to delete it anyway", false},
+	} {
+		assert.EqualError(t, tc.err, tc.message)
+		assert.ErrorIs(t, tc.err, tc.sentinel, tc.message)
+		assert.Equal(t, tc.cause, errors.Is(tc.err, cause), tc.message)
+		assert.NotErrorIs(t, tc.err, ErrServerNotStarted, tc.message)
+	}
+}
+
+func Test_Errors_WrappedCauseIsReachable(t *testing.T) {
+	err := &InitDBError{Command: "initdb", Err: &exec.ExitError{}, Log: ""}
+
+	var exitErr *exec.ExitError
+	assert.True(t, errors.As(err, &exitErr))
+}
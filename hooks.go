@@ -0,0 +1,73 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Hook is a function run at a fixed point of the lifecycle, see Config.BeforeInit, Config.AfterInit, Config.AfterStart
+// and Config.BeforeStop. Returning an error aborts Start or Stop, the error is returned wrapped in a HookError.
+type Hook func(ctx context.Context, env HookEnv) error
+
+// HookEnv describes the Postgres instance a Hook runs for.
+type HookEnv struct {
+	BinariesPath string
+	RuntimePath  string
+	DataPath     string
+	// Host is the host connections are made to, the socket directory when listening on a Unix socket.
+	Host     string
+	Port     uint32
+	Database string
+	// DB is a connection to the configured database. It is nil for BeforeInit and AfterInit, as Postgres is not
+	// running yet, and is closed once the hook returns.
+	DB *sql.DB
+}
+
+// runHook runs the hook named name when configured, connecting to the configured database first when connect is set.
+func (ep *EmbeddedPostgres) runHook(ctx context.Context, name string, hook Hook, connect bool) (err error) {
+	if hook == nil {
+		return nil
+	}
+
+	env := HookEnv{
+		BinariesPath: ep.config.binariesPath,
+		RuntimePath:  ep.config.runtimePath,
+		DataPath:     ep.config.dataPath,
+		Host:         ep.config.connectionHost(),
+		Port:         ep.config.port,
+		Database:     ep.config.database,
+	}
+
+	if connect {
+		conn, err := openDatabaseConnection(env.Host, env.Port, ep.config.username, ep.config.password, env.Database)
+		if err != nil {
+			return &HookError{Hook: name, Err: err}
+		}
+
+		db := sql.OpenDB(conn)
+		defer func() {
+			err = connectionClose(db, err)
+		}()
+
+		env.DB = db
+
+		ep.setHookRunning(true)
+		defer ep.setHookRunning(false)
+	}
+
+	if err := hook(ctx, env); err != nil {
+		return &HookError{Hook: name, Err: err}
+	}
+
+	return nil
+}
+
+// setHookRunning marks that the AfterStart or BeforeStop hook is running. Start and Stop hold the lifecycle lock while
+// these hooks run, so the operations a hook may call, such as Info, Reload and ExecSQL, check hookRunning rather than
+// waiting for the lock, and Stop does not wait for a Start whose AfterStart hook may be the one calling it.
+func (ep *EmbeddedPostgres) setHookRunning(running bool) {
+	ep.stateMu.Lock()
+	defer ep.stateMu.Unlock()
+
+	ep.hookRunning = running
+}
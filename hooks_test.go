@@ -0,0 +1,189 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func recordingHook(calls *[]string, name string, err error) Hook {
+	return func(ctx context.Context, env HookEnv) error {
+		*calls = append(*calls, name)
+		return err
+	}
+}
+
+func Test_Hooks_RunAroundInit(t *testing.T) {
+	var calls []string
+
+	var env HookEnv
+	database := fakeBinariesDatabase(t, DefaultConfig().
+		BeforeInit(recordingHook(&calls, "BeforeInit", nil)).
+		AfterInit(func(ctx context.Context, hookEnv HookEnv) error {
+			calls = append(calls, "AfterInit")
+			env = hookEnv
+
+			return nil
+		}).
+		AfterStart(recordingHook(&calls, "AfterStart", nil)), "1", &calls)
+
+	err := database.Start()
+
+	assert.ErrorIs(t, err, ErrStartFailed)
+	assert.Equal(t, []string{"BeforeInit", "initdb", "AfterInit"}, calls)
+	assert.Equal(t, database.config.dataPath, env.DataPath)
+	assert.Equal(t, database.config.binariesPath, env.BinariesPath)
+	assert.Equal(t, database.Port(), env.Port)
+	assert.Nil(t, env.DB)
+}
+
+func Test_Hooks_BeforeInitErrorAbortsStart(t *testing.T) {
+	var calls []string
+
+	failure := errors.New("not today")
+	database := fakeBinariesDatabase(t, DefaultConfig().
+		BeforeInit(recordingHook(&calls, "BeforeInit", failure)).
+		AfterInit(recordingHook(&calls, "AfterInit", nil)), "1", &calls)
+
+	err := database.Start()
+
+	assert.EqualError(t, err, "BeforeInit hook failed: not today")
+	assert.ErrorIs(t, err, ErrHookFailed)
+	assert.ErrorIs(t, err, failure)
+	assert.Equal(t, []string{"BeforeInit"}, calls)
+	assert.Equal(t, StateFailed, database.State())
+	assert.NoDirExists(t, database.config.dataPath)
+}
+
+func Test_Hooks_AfterInitErrorAbortsStart(t *testing.T) {
+	var calls []string
+
+	database := fakeBinariesDatabase(t, DefaultConfig().
+		AfterInit(recordingHook(&calls, "AfterInit", errors.New("not today"))), "1", &calls)
+
+	err := database.Start()
+
+	var hookErr *HookError
+	require.True(t, errors.As(err, &hookErr))
+	assert.Equal(t, "AfterInit", hookErr.Hook)
+	assert.Equal(t, []string{"initdb", "AfterInit"}, calls)
+
+	// the data directory is not reused, so the hook runs again on a freshly initialised one
+	assert.ErrorIs(t, database.Start(), ErrHookFailed)
+	assert.Equal(t, []string{"initdb", "AfterInit", "initdb", "AfterInit"}, calls)
+}
+
+func Test_Hooks_BeforeStopErrorStillStops(t *testing.T) {
+	var calls []string
+
+	database := fakeBinariesDatabase(t, DefaultConfig().
+		BeforeStop(func(ctx context.Context, env HookEnv) error {
+			calls = append(calls, "BeforeStop")
+
+			if env.DB == nil {
+				return errors.New("no connection")
+			}
+
+			return errors.New("not today")
+		}), "0", &calls)
+
+	logger, err := newSyncedLogger("", nil)
+	require.NoError(t, err)
+
+	database.syncedLogger = logger
+	database.done = make(chan struct{})
+	database.state = StateRunning
+
+	err = database.Stop()
+
+	assert.EqualError(t, err, "BeforeStop hook failed: not today")
+	assert.Equal(t, []string{"BeforeStop"}, calls)
+	assert.Equal(t, StateStopped, database.State())
+}
+
+func Test_Hooks_AfterStart(t *testing.T) {
+	var migrated bool
+
+	database := NewDatabase(DefaultConfig().
+		Port(9842).
+		AfterStart(func(ctx context.Context, env HookEnv) error {
+			if _, err := env.DB.ExecContext(ctx, "CREATE TABLE migrated (id int)"); err != nil {
+				return err
+			}
+
+			migrated = true
+
+			return nil
+		}).
+		BeforeStop(func(ctx context.Context, env HookEnv) error {
+			_, err := env.DB.ExecContext(ctx, "SELECT count(*) FROM migrated")
+			return err
+		}))
+	if err := database.Start(); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	if err := database.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, migrated)
+}
+
+func Test_Hooks_AfterStartErrorStopsPostgres(t *testing.T) {
+	database := NewDatabase(DefaultConfig().
+		Port(9843).
+		AfterStart(func(ctx context.Context, env HookEnv) error {
+			return errors.New("migration failed")
+		}))
+
+	err := database.Start()
+
+	assert.EqualError(t, err, "AfterStart hook failed: migration failed")
+	assert.Equal(t, StateFailed, database.State())
+
+	// the port is free again as Postgres was stopped
+	reservation, err := reservePort(DefaultConfig().Port(9843))
+	require.NoError(t, err)
+	reservation.release()
+}
+
+func Test_Hooks_StopFromAfterStartAbortsStart(t *testing.T) {
+	var stopErr error
+
+	var database *EmbeddedPostgres
+	database, _ = fixtureDatabase(t, DefaultConfig().
+		UnixSocketDirectory(t.TempDir()).
+		TCP(false).
+		Port(9855).
+		AfterStart(func(ctx context.Context, env HookEnv) error {
+			stopErr = database.Stop()
+			return nil
+		}), map[string]string{"pg_ctl": exitScript("0")})
+	database.initDatabase = func(ctx context.Context, binaryExtractLocation, runtimePath, pgDataDir, username, password, locale string, encoding string, logger *os.File, runAs *osUser, options initDBOptions) error {
+		return nil
+	}
+
+	fakePostgresServer(t, database.config.unixSocketDirectory, 9855)
+
+	started := make(chan error, 1)
+
+	go func() {
+		started <- database.Start()
+	}()
+
+	select {
+	case err := <-started:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(10 * time.Second):
+		t.Fatal("Stop called from the AfterStart hook did not return")
+	}
+
+	assert.ErrorIs(t, stopErr, ErrServerStarting)
+	assert.Equal(t, StateFailed, database.State())
+}
@@ -0,0 +1,81 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// InstanceInfo describes a running Postgres instance as returned by EmbeddedPostgres.Info.
+type InstanceInfo struct {
+	PID             int
+	Port            uint32
+	ListenAddresses []string
+	BinariesPath    string
+	RuntimePath     string
+	DataPath        string
+	ServerVersion   string
+	StartedAt       time.Time
+	Uptime          time.Duration
+	// DataReused is true when Start found an existing data directory for the same major version and used it, rather
+	// than initialising a new one.
+	DataReused bool
+	// Attached is true when Start attached to a server that was already running on the data directory.
+	Attached bool
+}
+
+// Info returns the process, paths and version details of the running Postgres instance. It can also be called from the
+// AfterStart and BeforeStop hooks.
+func (ep *EmbeddedPostgres) Info() (info InstanceInfo, err error) {
+	server, err := ep.snapshotServer()
+	if err != nil {
+		return InstanceInfo{}, err
+	}
+
+	config := server.config
+
+	pidFile, _, err := readPostmasterPidFile(config.dataPath)
+	if err != nil {
+		return InstanceInfo{}, err
+	}
+
+	info = InstanceInfo{
+		PID:          pidFile.pid,
+		Port:         config.port,
+		BinariesPath: config.binariesPath,
+		RuntimePath:  config.runtimePath,
+		DataPath:     config.dataPath,
+		DataReused:   server.dataReused,
+		Attached:     server.attached,
+	}
+
+	ctx, cancelFunc := context.WithTimeout(context.Background(), config.startTimeout)
+	defer cancelFunc()
+
+	conn, err := openDatabaseConnection(config.connectionHost(), config.port, config.username, config.password, config.database)
+	if err != nil {
+		return InstanceInfo{}, err
+	}
+
+	db := sql.OpenDB(conn)
+	defer func() {
+		err = connectionClose(db, err)
+	}()
+
+	var listenAddresses string
+	if err := db.QueryRowContext(ctx, "SELECT current_setting('server_version'), current_setting('listen_addresses'), pg_postmaster_start_time()").
+		Scan(&info.ServerVersion, &listenAddresses, &info.StartedAt); err != nil {
+		return InstanceInfo{}, err
+	}
+
+	for _, address := range strings.Split(listenAddresses, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			info.ListenAddresses = append(info.ListenAddresses, address)
+		}
+	}
+
+	info.Uptime = time.Since(info.StartedAt)
+
+	return info, nil
+}
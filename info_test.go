@@ -0,0 +1,77 @@
+package embeddedpostgres
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Info_ErrorWhenNotStarted(t *testing.T) {
+	database := NewDatabase()
+
+	_, err := database.Info()
+
+	assert.ErrorIs(t, err, ErrServerNotStarted)
+}
+
+func Test_Info(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "embedded_postgres_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	database := NewDatabase(DefaultConfig().
+		Version(V15).
+		Port(9839).
+		DataPath(tempDir))
+
+	if err := database.Start(); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	info, err := database.Info()
+	if err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	assert.NotZero(t, info.PID)
+	assert.Equal(t, uint32(9839), info.Port)
+	assert.Equal(t, []string{"localhost"}, info.ListenAddresses)
+	assert.Equal(t, tempDir, info.DataPath)
+	assert.NotEmpty(t, info.RuntimePath)
+	assert.Equal(t, info.RuntimePath, info.BinariesPath)
+	assert.True(t, strings.HasPrefix(info.ServerVersion, "15."), info.ServerVersion)
+	assert.Greater(t, info.Uptime.Nanoseconds(), int64(0))
+	assert.False(t, info.DataReused)
+	assert.Equal(t, info.RuntimePath, filepath.Dir(database.LogFile()))
+
+	if err := database.Stop(); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	assert.NoFileExists(t, database.LogFile())
+
+	if err := database.Start(); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	info, err = database.Info()
+	if err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	assert.True(t, info.DataReused)
+
+	if err := database.Stop(); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+}
@@ -0,0 +1,124 @@
+package embeddedpostgres
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// psqlErrorLine matches the line psql reports an error in, as in "psql:/init/01-schema.sql:12: ERROR:  ...".
+var psqlErrorLine = regexp.MustCompile(`(?m)^psql:.*:(\d+): (?:ERROR|FATAL)`)
+
+// runInitScripts runs the files of the InitScriptsDir in lexical order the way the official Docker image runs
+// /docker-entrypoint-initdb.d: *.sql and *.sql.gz files using psql and *.sh files using sh, ignoring any others.
+func (ep *EmbeddedPostgres) runInitScripts(ctx context.Context) error {
+	entries, err := os.ReadDir(ep.config.initScriptsDir)
+	if err != nil {
+		return fmt.Errorf("unable to read init scripts directory %s with error: %w", ep.config.initScriptsDir, err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		path := filepath.Join(ep.config.initScriptsDir, entry.Name())
+
+		switch {
+		case strings.HasSuffix(entry.Name(), ".sql"):
+			err = ep.runSQLScript(ctx, path, nil, "-f", path)
+		case strings.HasSuffix(entry.Name(), ".sql.gz"):
+			err = ep.runCompressedSQLScript(ctx, path)
+		case strings.HasSuffix(entry.Name(), ".sh"):
+			err = ep.runShellScript(ctx, path)
+		default:
+			_, err = fmt.Fprintf(ep.syncedLogger.file, "ignoring init script %s\n", path)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (ep *EmbeddedPostgres) runCompressedSQLScript(ctx context.Context, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return &InitScriptError{File: path, Err: err}
+	}
+
+	defer file.Close()
+
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return &InitScriptError{File: path, Err: err}
+	}
+
+	return ep.runSQLScript(ctx, path, reader, "-f", "-")
+}
+
+func (ep *EmbeddedPostgres) runSQLScript(ctx context.Context, path string, stdin io.Reader, args ...string) error {
+	cmd := psqlCommand(ctx, ep.config, ep.runAs, args...)
+	cmd.Stdin = stdin
+
+	output, err := runLogged(cmd, ep.syncedLogger.file)
+	if err != nil {
+		scriptErr := &InitScriptError{File: path, Err: err, Output: output}
+
+		if matches := psqlErrorLine.FindAllStringSubmatch(output, -1); len(matches) > 0 {
+			scriptErr.Line, _ = strconv.Atoi(matches[len(matches)-1][1])
+		}
+
+		return scriptErr
+	}
+
+	return nil
+}
+
+func (ep *EmbeddedPostgres) runShellScript(ctx context.Context, path string) error {
+	// the script runs from its own directory, where a path relative to the working directory no longer resolves
+	script, err := filepath.Abs(path)
+	if err != nil {
+		return &InitScriptError{File: path, Err: err}
+	}
+
+	cmd := exec.CommandContext(ctx, "sh", script)
+
+	// like the Docker image, executable scripts run on their own and others are run by the shell
+	if info, err := os.Stat(script); err == nil && info.Mode()&0111 != 0 && runtime.GOOS != "windows" {
+		cmd = exec.CommandContext(ctx, script)
+	}
+
+	cmd.Dir = filepath.Dir(script)
+	cmd.Env = clientEnv(ep.config)
+	ep.runAs.apply(cmd)
+
+	if output, err := runLogged(cmd, ep.syncedLogger.file); err != nil {
+		return &InitScriptError{File: path, Err: err, Output: output}
+	}
+
+	return nil
+}
+
+// runLogged runs cmd writing its output to the log, returning the output as well.
+func runLogged(cmd *exec.Cmd, logger io.Writer) (string, error) {
+	var output bytes.Buffer
+
+	cmd.Stdout = io.MultiWriter(&output, logger)
+	cmd.Stderr = cmd.Stdout
+
+	err := cmd.Run()
+
+	return output.String(), err
+}
@@ -0,0 +1,222 @@
+package embeddedpostgres
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePsql stands in for psql, recording each call and failing with a psql style error for files named *fail*.
+const fakePsql = `#!/bin/sh
+echo "psql $PGDATABASE $6 $(cat -)" >> "$INIT_SCRIPTS_CALLS"
+case "$6" in
+*fail*) echo "psql:$6:2: ERROR:  syntax error at or near \"SELEC\"" >&2; exit 3 ;;
+esac
+`
+
+func initScriptsTestDatabase(t *testing.T, scripts map[string]string) (*EmbeddedPostgres, string) {
+	database, tempDir := fixtureDatabase(t, DefaultConfig().
+		Database("beer").
+		Port(9849), map[string]string{"psql": fakePsql})
+
+	scriptsDir := filepath.Join(tempDir, "initdb.d")
+	require.NoError(t, os.MkdirAll(scriptsDir, os.ModePerm))
+
+	for name, content := range scripts {
+		path := filepath.Join(scriptsDir, name)
+
+		if strings.HasSuffix(name, ".gz") {
+			file, err := os.Create(path)
+			require.NoError(t, err)
+
+			writer := gzip.NewWriter(file)
+			_, err = writer.Write([]byte(content))
+			require.NoError(t, err)
+			require.NoError(t, writer.Close())
+			require.NoError(t, file.Close())
+
+			continue
+		}
+
+		require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	}
+
+	calls := filepath.Join(tempDir, "calls")
+	t.Setenv("INIT_SCRIPTS_CALLS", calls)
+
+	database.config = database.config.InitScriptsDir(scriptsDir)
+
+	return database, calls
+}
+
+func Test_runInitScripts_RunsFilesInLexicalOrder(t *testing.T) {
+	database, calls := initScriptsTestDatabase(t, map[string]string{
+		"02-data.sql.gz": "INSERT INTO beers VALUES (1);",
+		"01-schema.sql":  "CREATE TABLE beers (id int);",
+		"03-setup.sh":    `echo "sh $PGHOST $PGPORT $PGUSER $POSTGRES_DB $(command -v psql)" >> "$INIT_SCRIPTS_CALLS"`,
+		"04-README.md":   "not a script",
+	})
+
+	require.NoError(t, database.runInitScripts(context.Background()))
+
+	content, err := os.ReadFile(calls)
+	require.NoError(t, err)
+
+	scriptsDir := database.config.initScriptsDir
+	assert.Equal(t, []string{
+		"psql beer " + filepath.Join(scriptsDir, "01-schema.sql") + " ",
+		"psql beer - INSERT INTO beers VALUES (1);",
+		"sh localhost 9849 postgres beer " + filepath.Join(database.config.binariesPath, "bin", "psql"),
+	}, strings.Split(strings.TrimSpace(string(content)), "\n"))
+}
+
+func Test_runInitScripts_RelativeInitScriptsDir(t *testing.T) {
+	database, calls := initScriptsTestDatabase(t, map[string]string{
+		"01-setup.sh": `echo "sh $(pwd)" >> "$INIT_SCRIPTS_CALLS"`,
+	})
+
+	workingDir, err := os.Getwd()
+	require.NoError(t, err)
+
+	scriptsDir := database.config.initScriptsDir
+	relativeDir, err := filepath.Rel(workingDir, scriptsDir)
+	require.NoError(t, err)
+
+	database.config = database.config.InitScriptsDir(relativeDir)
+
+	require.NoError(t, database.runInitScripts(context.Background()))
+
+	content, err := os.ReadFile(calls)
+	require.NoError(t, err)
+
+	resolvedDir, err := filepath.EvalSymlinks(scriptsDir)
+	require.NoError(t, err)
+	assert.Equal(t, "sh "+resolvedDir+"\n", string(content))
+}
+
+func Test_runInitScripts_ReportsFailingFileAndLine(t *testing.T) {
+	database, calls := initScriptsTestDatabase(t, map[string]string{
+		"01-fail.sql": "SELECT 1;\nSELEC 2;",
+		"02-next.sql": "SELECT 3;",
+	})
+
+	err := database.runInitScripts(context.Background())
+
+	path := filepath.Join(database.config.initScriptsDir, "01-fail.sql")
+
+	assert.ErrorIs(t, err, ErrInitScriptFailed)
+	assert.ErrorContains(t, err, "init script "+path+":2 failed: exit status 3")
+
+	var scriptErr *InitScriptError
+	require.ErrorAs(t, err, &scriptErr)
+	assert.Equal(t, path, scriptErr.File)
+	assert.Equal(t, 2, scriptErr.Line)
+	assert.Contains(t, scriptErr.Output, `syntax error at or near "SELEC"`)
+
+	content, err := os.ReadFile(calls)
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(content), "\n"), "later scripts must not run")
+}
+
+func Test_runInitScripts_ReportsFailingShellScript(t *testing.T) {
+	database, _ := initScriptsTestDatabase(t, map[string]string{
+		"01-fail.sh": "echo broken >&2\nexit 1",
+	})
+
+	err := database.runInitScripts(context.Background())
+
+	assert.ErrorIs(t, err, ErrInitScriptFailed)
+	assert.EqualError(t, err, "init script "+filepath.Join(database.config.initScriptsDir, "01-fail.sh")+" failed: exit status 1\nbroken\n")
+}
+
+func Test_InitScripts(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("runs a shell script")
+	}
+
+	tempDir, err := os.MkdirTemp("", "embedded_postgres_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	scriptsDir := filepath.Join(tempDir, "initdb.d")
+	require.NoError(t, os.MkdirAll(scriptsDir, os.ModePerm))
+	require.NoError(t, os.WriteFile(filepath.Join(scriptsDir, "01-schema.sql"), []byte("CREATE TABLE beers (name text);"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(scriptsDir, "02-data.sh"), []byte(`psql -c "INSERT INTO beers VALUES ('$POSTGRES_USER')"`), 0600))
+
+	database := NewDatabase(DefaultConfig().
+		Port(9850).
+		RuntimePath(filepath.Join(tempDir, "runtime")).
+		DataPath(filepath.Join(tempDir, "data")).
+		InitScriptsDir(scriptsDir))
+
+	for i := 0; i < 2; i++ {
+		// the scripts only run on the first start, which initialises the data directory
+		if err := database.Start(); err != nil {
+			shutdownDBAndFail(t, err, database)
+		}
+
+		db, err := sql.Open("postgres", database.GetConnectionURL()+"?sslmode=disable")
+		if err != nil {
+			shutdownDBAndFail(t, err, database)
+		}
+
+		var count int
+		if err := db.QueryRow("SELECT count(*) FROM beers WHERE name = 'postgres'").Scan(&count); err != nil {
+			shutdownDBAndFail(t, err, database)
+		}
+
+		assert.Equal(t, 1, count)
+
+		if err := db.Close(); err != nil {
+			shutdownDBAndFail(t, err, database)
+		}
+
+		if err := database.Stop(); err != nil {
+			shutdownDBAndFail(t, err, database)
+		}
+	}
+}
+
+func Test_InitScripts_FailureRemovesDataDirectory(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "embedded_postgres_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	scriptsDir := filepath.Join(tempDir, "initdb.d")
+	require.NoError(t, os.MkdirAll(scriptsDir, os.ModePerm))
+	require.NoError(t, os.WriteFile(filepath.Join(scriptsDir, "01-fail.sql"), []byte("SELEC 1;"), 0600))
+
+	dataPath := filepath.Join(tempDir, "data")
+	database := NewDatabase(DefaultConfig().
+		Port(9852).
+		RuntimePath(filepath.Join(tempDir, "runtime")).
+		DataPath(dataPath).
+		InitScriptsDir(scriptsDir))
+
+	err = database.Start()
+
+	assert.ErrorIs(t, err, ErrInitScriptFailed)
+	assert.NoDirExists(t, dataPath, "the scripts must run again on the next start")
+}
@@ -0,0 +1,174 @@
+package embeddedpostgres
+
+import (
+	"context"
+)
+
+// State describes the stage of its lifecycle an EmbeddedPostgres is in.
+type State int
+
+// Lifecycle states, an EmbeddedPostgres starts out as StateStopped.
+const (
+	// StateStopped is the state before Start is first called and after Stop has completed.
+	StateStopped State = iota
+	// StateStarting is the state while Start or Restart is bringing up the Postgres process.
+	StateStarting
+	// StateRunning is the state once Start has completed successfully.
+	StateRunning
+	// StateStopping is the state while Stop or Restart is shutting down the Postgres process.
+	StateStopping
+	// StateFailed is the state after Start failed, or after a Supervised process exited without Stop being called.
+	StateFailed
+)
+
+func (s State) String() string {
+	switch s {
+	case StateStopped:
+		return "stopped"
+	case StateStarting:
+		return "starting"
+	case StateRunning:
+		return "running"
+	case StateStopping:
+		return "stopping"
+	case StateFailed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
+// State returns the current lifecycle state, it is safe to call at any time from any goroutine.
+func (ep *EmbeddedPostgres) State() State {
+	ep.stateMu.Lock()
+	defer ep.stateMu.Unlock()
+
+	return ep.currentState()
+}
+
+// currentState must be called with stateMu held.
+func (ep *EmbeddedPostgres) currentState() State {
+	if ep.state == StateRunning && ep.crashed() {
+		return StateFailed
+	}
+
+	return ep.state
+}
+
+// crashed reports whether the supervised process exited while running, it must be called with stateMu held.
+func (ep *EmbeddedPostgres) crashed() bool {
+	return ep.runningPostmaster != nil && ep.runningPostmaster.exited() && !ep.runningPostmaster.exitExpected()
+}
+
+// beginTransition moves to the next state when currently in one of the from states and returns the error describing
+// the current state otherwise. Moving to StateStarting registers cancel so that a concurrent Stop can abort it.
+// A supervised process that exited unexpectedly is still in StateRunning here, as it has to be stopped before it can
+// be started again.
+func (ep *EmbeddedPostgres) beginTransition(next State, cancel context.CancelFunc, from ...State) error {
+	ep.stateMu.Lock()
+	defer ep.stateMu.Unlock()
+
+	for _, state := range from {
+		if ep.state == state {
+			ep.setState(next, cancel)
+			return nil
+		}
+	}
+
+	return stateError(ep.state)
+}
+
+// endTransition moves to the final state of an operation, it must be called while holding the lifecycle lock.
+func (ep *EmbeddedPostgres) endTransition(next State) {
+	ep.stateMu.Lock()
+	defer ep.stateMu.Unlock()
+
+	ep.setState(next, nil)
+}
+
+// setState must be called with stateMu held.
+func (ep *EmbeddedPostgres) setState(next State, cancel context.CancelFunc) {
+	if ep.state == StateStarting && next != StateStarting {
+		ep.cancelStart = nil
+		close(ep.startDone)
+	}
+
+	if next == StateStarting {
+		ep.cancelStart = cancel
+		ep.startDone = make(chan struct{})
+	}
+
+	ep.runningPostmaster = nil
+	if next == StateRunning {
+		ep.runningPostmaster = ep.postmaster
+	}
+
+	ep.state = next
+}
+
+// abortStart cancels a Start that is in progress and waits for it to finish. It reports whether there was one, and
+// returns ErrServerStarting instead of waiting while the AfterStart hook runs, as the hook may be the caller.
+func (ep *EmbeddedPostgres) abortStart() (bool, error) {
+	ep.stateMu.Lock()
+
+	if ep.state != StateStarting {
+		ep.stateMu.Unlock()
+		return false, nil
+	}
+
+	cancel, startDone, hookRunning := ep.cancelStart, ep.startDone, ep.hookRunning
+	ep.stateMu.Unlock()
+
+	cancel()
+
+	if hookRunning {
+		return true, ErrServerStarting
+	}
+
+	<-startDone
+
+	return true, nil
+}
+
+// serverSnapshot holds the fields of a running server that operations which do not change the lifecycle State use.
+type serverSnapshot struct {
+	config     Config
+	runAs      *osUser
+	logger     *syncedLogger
+	dataReused bool
+	attached   bool
+}
+
+// snapshotServer returns the running server for operations that do not change the lifecycle State, such as Info and
+// Reload. It only checks the State under stateMu rather than taking the lifecycle lock, so that these operations return
+// an error instead of blocking while Start or Stop are in progress. The server is also up while the AfterStart and
+// BeforeStop hooks run, so they can be used from those hooks.
+func (ep *EmbeddedPostgres) snapshotServer() (serverSnapshot, error) {
+	ep.stateMu.Lock()
+	defer ep.stateMu.Unlock()
+
+	if state := ep.currentState(); state != StateRunning && !ep.hookRunning {
+		return serverSnapshot{}, stateError(state)
+	}
+
+	return serverSnapshot{
+		config:     ep.config,
+		runAs:      ep.runAs,
+		logger:     ep.syncedLogger,
+		dataReused: ep.dataReused,
+		attached:   ep.attached,
+	}, nil
+}
+
+func stateError(state State) error {
+	switch state {
+	case StateStarting:
+		return ErrServerStarting
+	case StateRunning:
+		return ErrServerAlreadyStarted
+	case StateStopping:
+		return ErrServerStopping
+	default:
+		return ErrServerNotStarted
+	}
+}
@@ -0,0 +1,176 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_State_String(t *testing.T) {
+	assert.Equal(t, "stopped", StateStopped.String())
+	assert.Equal(t, "starting", StateStarting.String())
+	assert.Equal(t, "running", StateRunning.String())
+	assert.Equal(t, "stopping", StateStopping.String())
+	assert.Equal(t, "failed", StateFailed.String())
+	assert.Equal(t, "unknown", State(42).String())
+}
+
+func Test_State_TransitionErrors(t *testing.T) {
+	database := NewDatabase()
+	assert.Equal(t, StateStopped, database.State())
+
+	assert.ErrorIs(t, database.Stop(), ErrServerNotStarted)
+
+	for state, expected := range map[State]error{
+		StateStarting: ErrServerStarting,
+		StateRunning:  ErrServerAlreadyStarted,
+		StateStopping: ErrServerStopping,
+	} {
+		database.state = state
+		assert.ErrorIs(t, database.Start(), expected, state.String())
+	}
+
+	database.state = StateStopping
+	_, err := database.Reload(map[string]string{"work_mem": "64MB"})
+	assert.ErrorIs(t, err, ErrServerStopping)
+	_, err = database.Info()
+	assert.ErrorIs(t, err, ErrServerStopping)
+}
+
+func Test_State_InfoAndReloadDoNotWaitForLifecycle(t *testing.T) {
+	database := NewDatabase()
+	database.state = StateStarting
+
+	database.lifecycle.Lock()
+	defer database.lifecycle.Unlock()
+
+	_, err := database.Info()
+	assert.ErrorIs(t, err, ErrServerStarting)
+	_, err = database.Reload(map[string]string{"work_mem": "64MB"})
+	assert.ErrorIs(t, err, ErrServerStarting)
+
+	database.setHookRunning(true)
+
+	_, err = database.Reload(map[string]string{"work_mem = 1": "64MB"})
+	assert.EqualError(t, err, `invalid parameter name "work_mem = 1"`)
+}
+
+// blockingStart returns a database whose Start blocks while fetching binaries until the fetch context is done,
+// along with a channel that is closed once the fetch has begun.
+func blockingStart(t *testing.T) (*EmbeddedPostgres, <-chan struct{}) {
+	tempDir, err := os.MkdirTemp("", "embedded_postgres_test")
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	})
+
+	database := NewDatabase(DefaultConfig().
+		Port(0).
+		RuntimePath(tempDir))
+	database.cacheLocator = func() (string, bool) {
+		return "", false
+	}
+
+	fetching := make(chan struct{})
+	database.remoteFetchStrategy = func(ctx context.Context) error {
+		close(fetching)
+		<-ctx.Done()
+
+		return ctx.Err()
+	}
+
+	return database, fetching
+}
+
+func Test_State_ConcurrentStart(t *testing.T) {
+	database, fetching := blockingStart(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan error, 1)
+
+	go func() {
+		started <- database.StartContext(ctx)
+	}()
+
+	<-fetching
+
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+
+	for i := range errs {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			errs[i] = database.Start()
+			_ = database.State()
+			_ = database.Port()
+			_ = database.GetConnectionURL()
+		}(i)
+	}
+
+	wg.Wait()
+
+	for _, err := range errs {
+		assert.ErrorIs(t, err, ErrServerStarting)
+	}
+
+	assert.Equal(t, StateStarting, database.State())
+
+	cancel()
+
+	assert.ErrorIs(t, <-started, context.Canceled)
+	assert.Equal(t, StateFailed, database.State())
+	assert.ErrorIs(t, database.Stop(), ErrServerNotStarted)
+}
+
+func Test_State_StopAbortsStart(t *testing.T) {
+	database, fetching := blockingStart(t)
+
+	started := make(chan error, 1)
+
+	go func() {
+		started <- database.Start()
+	}()
+
+	<-fetching
+
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+
+	for i := range errs {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			errs[i] = database.Stop()
+		}(i)
+	}
+
+	wg.Wait()
+
+	assert.ErrorIs(t, <-started, context.Canceled)
+	assert.Equal(t, StateStopped, database.State())
+
+	// exactly one Stop cleans up the aborted Start, the others find nothing left to stop
+	var stopped int
+
+	for _, err := range errs {
+		if err == nil {
+			stopped++
+		} else {
+			assert.ErrorIs(t, err, ErrServerNotStarted)
+		}
+	}
+
+	assert.Equal(t, 1, stopped)
+}
@@ -0,0 +1,21 @@
+package embeddedpostgres
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+func ensureLifetimeBindingSupported() error {
+	return nil
+}
+
+// bindLifetimeToParent asks the kernel to send the process SIGINT, a fast shutdown for Postgres, when its parent dies.
+func bindLifetimeToParent(cmd *exec.Cmd) error {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	cmd.SysProcAttr.Pdeathsig = syscall.SIGINT
+
+	return nil
+}
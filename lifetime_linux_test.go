@@ -0,0 +1,84 @@
+package embeddedpostgres
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Test_bindLifetimeToParent_HelperProcess is not a real test, it is run as the parent process whose death should stop
+// the child it starts.
+func Test_bindLifetimeToParent_HelperProcess(t *testing.T) {
+	if os.Getenv("EMBEDDED_POSTGRES_LIFETIME_HELPER") != "1" {
+		return
+	}
+
+	p := &postmaster{
+		cmd:  exec.Command("sleep", "60"),
+		done: make(chan struct{}),
+	}
+
+	if err := bindLifetimeToParent(p.cmd); err != nil {
+		panic(err)
+	}
+
+	if err := p.start(true); err != nil {
+		panic(err)
+	}
+
+	fmt.Println(p.cmd.Process.Pid)
+
+	// exit without any chance to clean up, as a panicking or killed test binary would
+	syscall.Exit(1)
+}
+
+func Test_bindLifetimeToParent_StopsChildWhenParentExits(t *testing.T) {
+	helper := exec.Command(os.Args[0], "-test.run=Test_bindLifetimeToParent_HelperProcess")
+	helper.Env = append(os.Environ(), "EMBEDDED_POSTGRES_LIFETIME_HELPER=1")
+
+	output, err := helper.StdoutPipe()
+	require.NoError(t, err)
+	require.NoError(t, helper.Start())
+
+	scanner := bufio.NewScanner(output)
+	require.True(t, scanner.Scan())
+
+	childPid, err := strconv.Atoi(scanner.Text())
+	require.NoError(t, err)
+
+	_ = helper.Wait()
+
+	assert.Eventually(t, func() bool {
+		return !processRunning(childPid)
+	}, 10*time.Second, 50*time.Millisecond)
+}
+
+// processRunning reports whether the process exists and has not yet terminated, an orphaned child might not be reaped
+// straight away inside containers and so remains as a zombie.
+func processRunning(pid int) bool {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return false
+	}
+
+	fields := strings.Fields(string(stat[strings.LastIndex(string(stat), ")")+1:]))
+
+	return len(fields) > 0 && fields[0] != "Z"
+}
+
+func Test_bindLifetimeToParent_SetsParentDeathSignal(t *testing.T) {
+	cmd := exec.Command("true")
+
+	require.NoError(t, bindLifetimeToParent(cmd))
+
+	assert.Equal(t, syscall.SIGINT, cmd.SysProcAttr.Pdeathsig)
+}
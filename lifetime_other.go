@@ -0,0 +1,18 @@
+//go:build !linux
+
+package embeddedpostgres
+
+import (
+	"errors"
+	"os/exec"
+)
+
+var errLifetimeBindingUnsupported = errors.New("StopOnProcessExit is only supported on linux")
+
+func ensureLifetimeBindingSupported() error {
+	return errLifetimeBindingUnsupported
+}
+
+func bindLifetimeToParent(_ *exec.Cmd) error {
+	return errLifetimeBindingUnsupported
+}
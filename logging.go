@@ -5,10 +5,14 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"strings"
+	"sync"
 	"time"
 )
 
 type syncedLogger struct {
+	// mu serialises flush, which Reload may call while Stop flushes the same logger
+	mu     sync.Mutex
 	offset int64
 	logger io.Writer
 	file   *os.File
@@ -29,10 +33,13 @@ func newSyncedLogger(dir string, logger io.Writer) (*syncedLogger, error) {
 }
 
 func (s *syncedLogger) flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	if s.logger != nil {
 		file, err := os.Open(s.file.Name())
 		if err != nil {
-			return fmt.Errorf("unable to process postgres logs: %s", err)
+			return fmt.Errorf("unable to process postgres logs: %w", err)
 		}
 
 		defer func() {
@@ -42,12 +49,12 @@ func (s *syncedLogger) flush() error {
 		}()
 
 		if _, err = file.Seek(s.offset, io.SeekStart); err != nil {
-			return fmt.Errorf("unable to process postgres logs: %s", err)
+			return fmt.Errorf("unable to process postgres logs: %w", err)
 		}
 
 		readBytes, err := io.Copy(s.logger, file)
 		if err != nil {
-			return fmt.Errorf("unable to process postgres logs: %s", err)
+			return fmt.Errorf("unable to process postgres logs: %w", err)
 		}
 
 		s.offset += readBytes
@@ -56,6 +63,63 @@ func (s *syncedLogger) flush() error {
 	return nil
 }
 
+// moveTo moves the log file into dir, returning a logger that carries on flushing from the same offset.
+func (s *syncedLogger) moveTo(dir string) (*syncedLogger, error) {
+	moved, err := newSyncedLogger(dir, s.logger)
+	if err != nil {
+		return nil, fmt.Errorf("unable to move log into %s: %w", dir, err)
+	}
+
+	content, err := os.ReadFile(s.file.Name())
+	if err != nil {
+		return nil, fmt.Errorf("unable to move log into %s: %w", dir, err)
+	}
+
+	if _, err := moved.file.Write(content); err != nil {
+		return nil, fmt.Errorf("unable to move log into %s: %w", dir, err)
+	}
+
+	moved.offset = s.offset
+
+	if err := s.remove(); err != nil {
+		return nil, err
+	}
+
+	return moved, nil
+}
+
+// remove closes and deletes the log file.
+func (s *syncedLogger) remove() error {
+	if err := s.file.Close(); err != nil {
+		return fmt.Errorf("unable to remove log %s: %w", s.file.Name(), err)
+	}
+
+	if err := os.Remove(s.file.Name()); err != nil {
+		return fmt.Errorf("unable to remove log %s: %w", s.file.Name(), err)
+	}
+
+	return nil
+}
+
+// tail returns the last lines of the log, or an empty string when it cannot be read.
+func (s *syncedLogger) tail(lines int) string {
+	if s == nil {
+		return ""
+	}
+
+	logContent, err := readLogsOrTimeout(s.file)
+	if err != nil {
+		return ""
+	}
+
+	logLines := strings.Split(strings.TrimRight(string(logContent), "\n"), "\n")
+	if len(logLines) > lines {
+		logLines = logLines[len(logLines)-lines:]
+	}
+
+	return strings.Join(logLines, "\n")
+}
+
 func readLogsOrTimeout(logger *os.File) (logContent []byte, err error) {
 	logContent = []byte("logs could not be read")
 
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"runtime"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -26,6 +28,22 @@ func Test_SyncedLogger_CreateError(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func Test_Start_ErrorWhenLoggerCannotBeCreated(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sets the temporary directory through TMPDIR")
+	}
+
+	// without a LogDirectory the log is created in the temporary directory
+	t.Setenv("TMPDIR", filepath.Join(t.TempDir(), "missing"))
+
+	database := NewDatabase()
+
+	err := database.Start()
+
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	assert.ErrorContains(t, err, "unable to create logger with error: ")
+}
+
 func Test_SyncedLogger_ErrorDuringFlush(t *testing.T) {
 	logger := customLogger{}
 
@@ -81,3 +99,60 @@ func Test_readLogsOrTimeout(t *testing.T) {
 	assert.Equal(t, []byte("logs could not be read"), logContent)
 	assert.EqualError(t, err, fmt.Sprintf("open %s: no such file or directory", logFile.Name()))
 }
+
+func Test_SyncedLogger_MoveTo(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "logging_test")
+	require.NoError(t, err)
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	logger := customLogger{}
+
+	sl, err := newSyncedLogger("", &logger)
+	require.NoError(t, err)
+
+	_, err = sl.file.Write([]byte("flushed\n"))
+	require.NoError(t, err)
+	require.NoError(t, sl.flush())
+
+	_, err = sl.file.Write([]byte("pending\n"))
+	require.NoError(t, err)
+
+	moved, err := sl.moveTo(tempDir)
+	require.NoError(t, err)
+
+	assert.NoFileExists(t, sl.file.Name())
+	assert.Equal(t, tempDir, filepath.Dir(moved.file.Name()))
+
+	_, err = moved.file.Write([]byte("after move\n"))
+	require.NoError(t, err)
+	require.NoError(t, moved.flush())
+
+	assert.Equal(t, "flushed\npending\nafter move\n", string(logger.logLines))
+}
+
+func Test_SyncedLogger_Remove(t *testing.T) {
+	sl, err := newSyncedLogger("", nil)
+	require.NoError(t, err)
+
+	assert.NoError(t, sl.remove())
+	assert.NoFileExists(t, sl.file.Name())
+}
+
+func Test_SyncedLogger_Tail(t *testing.T) {
+	sl, err := newSyncedLogger(t.TempDir(), nil)
+	require.NoError(t, err)
+
+	defer sl.file.Close()
+
+	_, err = sl.file.Write([]byte("one\ntwo\nthree\n"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "two\nthree", sl.tail(2))
+	assert.Equal(t, "one\ntwo\nthree", sl.tail(5))
+	assert.Equal(t, "", (*syncedLogger)(nil).tail(5))
+}
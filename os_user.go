@@ -0,0 +1,49 @@
+package embeddedpostgres
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// osUser is the operating system user initdb and Postgres are run as, see Config.OSUser.
+type osUser struct {
+	name string
+	uid  uint32
+	gid  uint32
+}
+
+// apply makes cmd run with the credentials of the user, it does nothing for a nil user.
+func (u *osUser) apply(cmd *exec.Cmd) {
+	if u == nil {
+		return
+	}
+
+	setCredential(cmd, u)
+}
+
+// chownAll recursively changes the owner of every existing path to the user, it does nothing for a nil user.
+func (u *osUser) chownAll(paths ...string) error {
+	if u == nil {
+		return nil
+	}
+
+	for _, path := range paths {
+		if _, err := os.Lstat(path); os.IsNotExist(err) {
+			continue
+		}
+
+		if err := filepath.Walk(path, func(name string, _ os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			return os.Lchown(name, int(u.uid), int(u.gid))
+		}); err != nil {
+			return fmt.Errorf("unable to change owner of %s to %s: %w", path, u.name, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+//go:build !windows
+
+package embeddedpostgres
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// lookupOSUser finds the user by name or numeric uid. It returns nil when that is the user the Go process already runs
+// as, in which case there is nothing to change.
+func lookupOSUser(name string) (*osUser, error) {
+	found, err := user.Lookup(name)
+	if err != nil {
+		if _, parseErr := strconv.ParseUint(name, 10, 32); parseErr != nil {
+			return nil, fmt.Errorf("unable to find OS user %s: %w", name, err)
+		}
+
+		if found, err = user.LookupId(name); err != nil {
+			return nil, fmt.Errorf("unable to find OS user %s: %w", name, err)
+		}
+	}
+
+	uid, err := strconv.ParseUint(found.Uid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse uid %s of OS user %s: %w", found.Uid, name, err)
+	}
+
+	gid, err := strconv.ParseUint(found.Gid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse gid %s of OS user %s: %w", found.Gid, name, err)
+	}
+
+	if int(uid) == os.Geteuid() {
+		return nil, nil
+	}
+
+	return &osUser{name: found.Username, uid: uint32(uid), gid: uint32(gid)}, nil
+}
+
+func setCredential(cmd *exec.Cmd, u *osUser) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	cmd.SysProcAttr.Credential = &syscall.Credential{Uid: u.uid, Gid: u.gid}
+}
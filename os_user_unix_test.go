@@ -0,0 +1,223 @@
+//go:build !windows
+
+package embeddedpostgres
+
+import (
+	"database/sql"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func nobodyUser(t *testing.T) *user.User {
+	nobody, err := user.Lookup("nobody")
+	if err != nil {
+		t.Skip("requires the nobody user")
+	}
+
+	if current, err := user.Current(); err == nil && current.Uid == nobody.Uid {
+		t.Skip("requires running as a user other than nobody")
+	}
+
+	return nobody
+}
+
+func Test_lookupOSUser_ByNameAndUid(t *testing.T) {
+	nobody := nobodyUser(t)
+
+	byName, err := lookupOSUser("nobody")
+	require.NoError(t, err)
+	require.NotNil(t, byName)
+
+	byUid, err := lookupOSUser(nobody.Uid)
+	require.NoError(t, err)
+
+	assert.Equal(t, byName, byUid)
+	assert.Equal(t, "nobody", byName.name)
+}
+
+func Test_lookupOSUser_NilWhenCurrentUser(t *testing.T) {
+	current, err := user.Current()
+	require.NoError(t, err)
+
+	runAs, err := lookupOSUser(current.Username)
+
+	assert.NoError(t, err)
+	assert.Nil(t, runAs)
+}
+
+func Test_lookupOSUser_ErrorWhenUnknown(t *testing.T) {
+	_, err := lookupOSUser("embedded-postgres-no-such-user")
+
+	assert.ErrorContains(t, err, "unable to find OS user embedded-postgres-no-such-user")
+}
+
+func Test_OSUser_ErrorWhenUnknown(t *testing.T) {
+	database := NewDatabase(DefaultConfig().OSUser("embedded-postgres-no-such-user"))
+
+	err := database.Start()
+
+	assert.ErrorContains(t, err, "unable to find OS user embedded-postgres-no-such-user")
+	assert.Equal(t, StateFailed, database.State())
+}
+
+func Test_osUser_apply(t *testing.T) {
+	cmd := exec.Command("true")
+
+	var runAs *osUser
+	runAs.apply(cmd)
+	assert.Nil(t, cmd.SysProcAttr)
+
+	runAs = &osUser{name: "nobody", uid: 65534, gid: 65533}
+	runAs.apply(cmd)
+	assert.Equal(t, &syscall.Credential{Uid: 65534, Gid: 65533}, cmd.SysProcAttr.Credential)
+}
+
+func Test_osUser_chownAll(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("changing the owner of files requires root")
+	}
+
+	nobody := nobodyUser(t)
+
+	tempDir, err := os.MkdirTemp("", "os_user_test")
+	require.NoError(t, err)
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "nested"), os.ModePerm))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "nested", "file"), []byte("data"), 0600))
+
+	runAs, err := lookupOSUser("nobody")
+	require.NoError(t, err)
+
+	require.NoError(t, runAs.chownAll(tempDir, filepath.Join(tempDir, "missing")))
+
+	for _, path := range []string{tempDir, filepath.Join(tempDir, "nested"), filepath.Join(tempDir, "nested", "file")} {
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, nobody.Uid, fileOwner(info), path)
+	}
+}
+
+func Test_prepareUnixSocketDirectory_ChownsCreatedDirectory(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("changing the owner of files requires root")
+	}
+
+	nobody := nobodyUser(t)
+
+	tempDir, err := os.MkdirTemp("", "os_user_test")
+	require.NoError(t, err)
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	socketDirectory := filepath.Join(tempDir, "sockets")
+
+	runAs, err := lookupOSUser("nobody")
+	require.NoError(t, err)
+
+	database := NewDatabase(DefaultConfig().UnixSocketDirectory(socketDirectory))
+	database.runAs = runAs
+
+	require.NoError(t, database.prepareUnixSocketDirectory())
+
+	info, err := os.Stat(socketDirectory)
+	require.NoError(t, err)
+	assert.Equal(t, nobody.Uid, fileOwner(info))
+
+	info, err = os.Stat(tempDir)
+	require.NoError(t, err)
+	assert.NotEqual(t, nobody.Uid, fileOwner(info), "existing directories must keep their owner")
+}
+
+func fileOwner(info os.FileInfo) string {
+	return strconv.FormatUint(uint64(info.Sys().(*syscall.Stat_t).Uid), 10)
+}
+
+func Test_OSUser_StartsWhenRunningAsRoot(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("running as another user requires root")
+	}
+
+	tempDir, err := os.MkdirTemp("", "os_user_test")
+	require.NoError(t, err)
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	// the user needs to be able to reach the runtime directory
+	require.NoError(t, os.Chmod(tempDir, 0755))
+
+	nobody := nobodyUser(t)
+
+	database := NewDatabase(DefaultConfig().
+		Port(9840).
+		RuntimePath(filepath.Join(tempDir, "runtime")).
+		OSUser("nobody"))
+	if err := database.Start(); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	db, err := sql.Open("postgres", database.GetConnectionURL())
+	if err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	if err := db.Ping(); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	if err := db.Close(); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	info, err := os.Stat(filepath.Join(tempDir, "runtime", "data", "PG_VERSION"))
+	if err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	if err := database.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, nobody.Uid, fileOwner(info))
+}
+
+func Test_osUser_apply_RunsAsUser(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("running as another user requires root")
+	}
+
+	nobody := nobodyUser(t)
+
+	runAs, err := lookupOSUser("nobody")
+	require.NoError(t, err)
+
+	cmd := exec.Command("id", "-u")
+	runAs.apply(cmd)
+
+	output, err := cmd.Output()
+	require.NoError(t, err)
+
+	assert.Equal(t, nobody.Uid, strings.TrimSpace(string(output)))
+}
@@ -0,0 +1,12 @@
+package embeddedpostgres
+
+import (
+	"errors"
+	"os/exec"
+)
+
+func lookupOSUser(_ string) (*osUser, error) {
+	return nil, errors.New("OSUser is not supported on windows")
+}
+
+func setCredential(_ *exec.Cmd, _ *osUser) {}
@@ -0,0 +1,134 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Phase names a step of starting Postgres, see Config.EventHandler and EmbeddedPostgres.StartResult.
+type Phase string
+
+// Phases of Start in the order they run. Phases that are not needed, such as downloading binaries that are already
+// cached, are skipped.
+const (
+	PhaseCacheLookup Phase = "cache lookup"
+	// PhaseDownload covers fetching the binaries with the RemoteFetchStrategy, including PhaseChecksum.
+	PhaseDownload       Phase = "download"
+	PhaseChecksum       Phase = "checksum"
+	PhaseExtract        Phase = "extract"
+	PhaseInitDB         Phase = "initdb"
+	PhaseStart          Phase = "start"
+	PhaseCreateDatabase Phase = "create database"
+	PhaseHealthCheck    Phase = "health check"
+	PhaseInitScripts    Phase = "init scripts"
+)
+
+// Event reports the progress of Start to the Config.EventHandler, once when a phase begins and once when it ends.
+type Event struct {
+	Phase Phase
+	// Finished is false when the phase begins and true once it has ended, successfully or with Err.
+	Finished bool
+	// Duration is the time the phase took, set once it has finished.
+	Duration time.Duration
+	Err      error
+}
+
+// PhaseTiming is the time a single phase of Start took.
+type PhaseTiming struct {
+	Phase    Phase
+	Duration time.Duration
+	Err      error
+}
+
+// StartResult describes the phases the last call to Start went through and how long each of them took.
+type StartResult struct {
+	Phases []PhaseTiming
+	// Total is the time Start took, which includes work between the phases such as reserving the port.
+	Total time.Duration
+}
+
+// Duration returns the time spent in phase, which is 0 when the phase was skipped.
+func (r StartResult) Duration(phase Phase) time.Duration {
+	var duration time.Duration
+
+	for _, timing := range r.Phases {
+		if timing.Phase == phase {
+			duration += timing.Duration
+		}
+	}
+
+	return duration
+}
+
+// StartResult returns the phase timings of the last call to Start, including one that failed.
+// It returns an empty StartResult before Start has been called.
+func (ep *EmbeddedPostgres) StartResult() StartResult {
+	ep.stateMu.Lock()
+	defer ep.stateMu.Unlock()
+
+	return ep.startResult
+}
+
+// phaseRecorder times the phases of a single Start and reports them to the event handler.
+type phaseRecorder struct {
+	mu      sync.Mutex
+	handler func(Event)
+	started time.Time
+	phases  []PhaseTiming
+}
+
+type phaseRecorderKey struct{}
+
+func newPhaseRecorder(handler func(Event)) *phaseRecorder {
+	return &phaseRecorder{handler: handler, started: time.Now()}
+}
+
+// withPhaseRecorder makes the recorder available to code that only receives ctx, such as the RemoteFetchStrategy.
+func withPhaseRecorder(ctx context.Context, recorder *phaseRecorder) context.Context {
+	return context.WithValue(ctx, phaseRecorderKey{}, recorder)
+}
+
+// phaseRecorderFromContext returns the recorder of the Start ctx belongs to, or nil when there is none.
+func phaseRecorderFromContext(ctx context.Context) *phaseRecorder {
+	recorder, _ := ctx.Value(phaseRecorderKey{}).(*phaseRecorder)
+	return recorder
+}
+
+// begin reports the start of phase and returns the function to call with its outcome once it has finished.
+// It is safe to call on a nil recorder, which records nothing.
+func (r *phaseRecorder) begin(phase Phase) func(err error) {
+	if r == nil {
+		return func(error) {}
+	}
+
+	r.notify(Event{Phase: phase})
+
+	began := time.Now()
+
+	return func(err error) {
+		duration := time.Since(began)
+
+		r.mu.Lock()
+		r.phases = append(r.phases, PhaseTiming{Phase: phase, Duration: duration, Err: err})
+		r.mu.Unlock()
+
+		r.notify(Event{Phase: phase, Finished: true, Duration: duration, Err: err})
+	}
+}
+
+func (r *phaseRecorder) notify(event Event) {
+	if r.handler != nil {
+		r.handler(event)
+	}
+}
+
+func (r *phaseRecorder) result() StartResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return StartResult{
+		Phases: append([]PhaseTiming(nil), r.phases...),
+		Total:  time.Since(r.started),
+	}
+}
@@ -0,0 +1,164 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// eventLog collects the events passed to an EventHandler.
+type eventLog struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (l *eventLog) handle(event Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.events = append(l.events, event)
+}
+
+func (l *eventLog) phases() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	phases := make([]string, 0, len(l.events))
+	for _, event := range l.events {
+		if event.Finished {
+			phases = append(phases, "finished "+string(event.Phase))
+		} else {
+			phases = append(phases, "began "+string(event.Phase))
+		}
+	}
+
+	return phases
+}
+
+func Test_phaseRecorder(t *testing.T) {
+	events := &eventLog{}
+	recorder := newPhaseRecorder(events.handle)
+	failure := errors.New("did not work")
+
+	recorder.begin(PhaseInitDB)(nil)
+	recorder.begin(PhaseStart)(failure)
+
+	assert.Equal(t, []string{"began initdb", "finished initdb", "began start", "finished start"}, events.phases())
+	assert.Equal(t, failure, events.events[3].Err)
+
+	result := recorder.result()
+	require.Len(t, result.Phases, 2)
+	assert.Equal(t, PhaseInitDB, result.Phases[0].Phase)
+	assert.Equal(t, PhaseStart, result.Phases[1].Phase)
+	assert.Equal(t, failure, result.Phases[1].Err)
+	assert.Equal(t, result.Phases[1].Duration, result.Duration(PhaseStart))
+	assert.Equal(t, events.events[3].Duration, result.Duration(PhaseStart))
+	assert.Zero(t, result.Duration(PhaseDownload))
+	assert.GreaterOrEqual(t, result.Total, result.Duration(PhaseInitDB)+result.Duration(PhaseStart))
+}
+
+func Test_phaseRecorder_NilIsNoop(t *testing.T) {
+	var recorder *phaseRecorder
+
+	recorder.begin(PhaseDownload)(nil)
+
+	assert.Nil(t, phaseRecorderFromContext(context.Background()))
+}
+
+func Test_StartResult_EmptyBeforeStart(t *testing.T) {
+	assert.Equal(t, StartResult{}, NewDatabase().StartResult())
+}
+
+func Test_StartResult_RecordsFailedStart(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "phases_test")
+	require.NoError(t, err)
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	events := &eventLog{}
+	database := NewDatabase(DefaultConfig().
+		Port(0).
+		RuntimePath(tempDir).
+		EventHandler(events.handle))
+	database.cacheLocator = func() (string, bool) {
+		return "", false
+	}
+	database.remoteFetchStrategy = func(ctx context.Context) error {
+		return errors.New("did not work")
+	}
+
+	err = database.Start()
+
+	assert.EqualError(t, err, "did not work")
+	assert.Equal(t, []string{"began cache lookup", "finished cache lookup", "began download", "finished download"}, events.phases())
+
+	result := database.StartResult()
+	require.Len(t, result.Phases, 2)
+	assert.Equal(t, PhaseDownload, result.Phases[1].Phase)
+	assert.EqualError(t, result.Phases[1].Err, "did not work")
+	assert.Greater(t, result.Total, time.Duration(0))
+}
+
+func Test_defaultRemoteFetchStrategy_RecordsChecksumPhase(t *testing.T) {
+	jarFile, cleanUp := createTempZipArchive()
+	defer cleanUp()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.RequestURI, ".sha256") {
+			_, _ = w.Write([]byte("literallyN3verGonnaWork"))
+			return
+		}
+
+		http.ServeFile(w, r, jarFile)
+	}))
+	defer server.Close()
+
+	events := &eventLog{}
+	recorder := newPhaseRecorder(events.handle)
+
+	remoteFetchStrategy := defaultRemoteFetchStrategy(server.URL+"/maven2",
+		testVersionStrategy(),
+		testCacheLocator())
+
+	err := remoteFetchStrategy(withPhaseRecorder(context.Background(), recorder))
+
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+	assert.Equal(t, []string{"began checksum", "finished checksum"}, events.phases())
+	assert.ErrorIs(t, recorder.result().Phases[0].Err, ErrChecksumMismatch)
+}
+
+func Test_StartResult(t *testing.T) {
+	events := &eventLog{}
+	database := NewDatabase(DefaultConfig().
+		Port(9841).
+		Database("phases").
+		EventHandler(events.handle))
+	if err := database.Start(); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	if err := database.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	result := database.StartResult()
+
+	for _, phase := range []Phase{PhaseCacheLookup, PhaseExtract, PhaseInitDB, PhaseStart, PhaseCreateDatabase, PhaseHealthCheck} {
+		assert.Greater(t, result.Duration(phase), time.Duration(0), phase)
+	}
+
+	assert.Len(t, events.phases(), 2*len(result.Phases))
+}
@@ -0,0 +1,150 @@
+package embeddedpostgres
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const maxPortAllocationAttempts = 50
+
+// portLockDirectoryMode lets the processes of every user reserve ports in the same directory, with the sticky bit
+// keeping them from removing each other's lock files as in the temporary directory itself.
+const portLockDirectoryMode = os.ModeSticky | 0777
+
+// portReservation holds a lock shared between processes on a port that is about to be bound by Postgres.
+// It prevents two embedded Postgres instances from choosing the same free port at the same time.
+type portReservation struct {
+	port uint32
+	lock *portLock
+}
+
+// reservePort reserves the configured port, or when the configured port is 0 chooses and reserves a free port,
+// optionally limited to the configured port range.
+func reservePort(config Config) (*portReservation, error) {
+	if config.port != 0 {
+		return tryReservePort(config, config.port)
+	}
+
+	if config.portRangeStart != 0 {
+		return reservePortInRange(config, config.portRangeStart, config.portRangeEnd)
+	}
+
+	for i := 0; i < maxPortAllocationAttempts; i++ {
+		port, err := freePort(config)
+		if err != nil {
+			return nil, err
+		}
+
+		if reservation, err := tryReservePort(config, port); err == nil {
+			return reservation, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unable to find a free port after %d attempts: %w", maxPortAllocationAttempts, ErrPortInUse)
+}
+
+func reservePortInRange(config Config, start, end uint32) (*portReservation, error) {
+	if end < start {
+		return nil, fmt.Errorf("%w %d-%d", ErrInvalidPortRange, start, end)
+	}
+
+	candidates := make([]uint32, 0, end-start+1)
+	for port := start; port <= end; port++ {
+		candidates = append(candidates, port)
+	}
+
+	// shuffle so that concurrent processes do not all contend for the start of the range
+	random := rand.New(rand.NewSource(time.Now().UnixNano())) //nolint:gosec
+	random.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+
+	for _, port := range candidates {
+		if reservation, err := tryReservePort(config, port); err == nil {
+			return reservation, nil
+		}
+	}
+
+	return nil, &PortInUseError{PortRangeStart: start, PortRangeEnd: end}
+}
+
+func tryReservePort(config Config, port uint32) (*portReservation, error) {
+	lock, err := lockPort(port)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ensurePortAvailable(config, port); err != nil {
+		lock.release()
+		return nil, err
+	}
+
+	return &portReservation{port: port, lock: lock}, nil
+}
+
+// release allows other processes to reserve the port again, it is safe to call on a nil reservation.
+func (r *portReservation) release() {
+	if r == nil {
+		return
+	}
+
+	r.lock.release()
+}
+
+func freePort(config Config) (uint32, error) {
+	listener, err := net.Listen("tcp", net.JoinHostPort(config.probeAddresses()[0], "0"))
+	if err != nil {
+		return 0, fmt.Errorf("unable to find a free port: %w", err)
+	}
+
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	if err := listener.Close(); err != nil {
+		return 0, err
+	}
+
+	return uint32(port), nil
+}
+
+// ensurePortAvailable checks that nothing listens on the TCP port of any of the listen addresses yet, which cannot
+// conflict when TCP is disabled.
+func ensurePortAvailable(config Config, port uint32) error {
+	if config.disableTCP {
+		return nil
+	}
+
+	for _, address := range config.probeAddresses() {
+		conn, err := net.Listen("tcp", net.JoinHostPort(address, strconv.FormatUint(uint64(port), 10)))
+		if err != nil {
+			return &PortInUseError{Port: port, Err: err}
+		}
+
+		if err := conn.Close(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func portLockPath(port uint32) (string, error) {
+	lockDirectory := filepath.Join(os.TempDir(), "embedded-postgres-go-ports")
+	if err := os.Mkdir(lockDirectory, portLockDirectoryMode); err != nil {
+		if !os.IsExist(err) {
+			return "", fmt.Errorf("unable to create port lock directory %s: %w", lockDirectory, err)
+		}
+
+		// older versions created the directory without write access for other users, which only its owner can fix
+		_ = os.Chmod(lockDirectory, portLockDirectoryMode)
+	} else if err := os.Chmod(lockDirectory, portLockDirectoryMode); err != nil {
+		// the umask has been applied to the mode passed to Mkdir
+		return "", fmt.Errorf("unable to create port lock directory %s: %w", lockDirectory, err)
+	}
+
+	return filepath.Join(lockDirectory, fmt.Sprintf("%d.lock", port)), nil
+}
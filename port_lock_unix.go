@@ -0,0 +1,39 @@
+//go:build !windows
+
+package embeddedpostgres
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// portLock is an advisory file lock, it is released by the operating system if the owning process dies.
+type portLock struct {
+	file *os.File
+}
+
+func lockPort(port uint32) (*portLock, error) {
+	lockPath, err := portLockPath(port)
+	if err != nil {
+		return nil, err
+	}
+
+	// flock does not need write access, so the lock file of a port can be opened by every user that reserves it
+	file, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open port lock %s: %w", lockPath, err)
+	}
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		_ = file.Close()
+		return nil, &PortInUseError{Port: port, Reserved: true, Err: err}
+	}
+
+	return &portLock{file: file}, nil
+}
+
+func (l *portLock) release() {
+	_ = syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN)
+	_ = l.file.Close()
+}
@@ -0,0 +1,37 @@
+//go:build windows
+
+package embeddedpostgres
+
+import (
+	"os"
+)
+
+// portLock is an exclusively created file that is held open for as long as the port is reserved.
+// Windows refuses to remove a file that another process has open, so a lock file left behind by a dead process can be
+// removed while one held by a live process cannot.
+type portLock struct {
+	file *os.File
+}
+
+func lockPort(port uint32) (*portLock, error) {
+	lockPath, err := portLockPath(port)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Remove(lockPath); err != nil && !os.IsNotExist(err) {
+		return nil, &PortInUseError{Port: port, Reserved: true, Err: err}
+	}
+
+	file, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0666)
+	if err != nil {
+		return nil, &PortInUseError{Port: port, Reserved: true, Err: err}
+	}
+
+	return &portLock{file: file}, nil
+}
+
+func (l *portLock) release() {
+	_ = l.file.Close()
+	_ = os.Remove(l.file.Name())
+}
@@ -0,0 +1,103 @@
+package embeddedpostgres
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_reservePort_ChoosesFreePort(t *testing.T) {
+	first, err := reservePort(DefaultConfig().Port(0))
+	require.NoError(t, err)
+	defer first.release()
+
+	second, err := reservePort(DefaultConfig().Port(0))
+	require.NoError(t, err)
+	defer second.release()
+
+	assert.NotZero(t, first.port)
+	assert.NotZero(t, second.port)
+	assert.NotEqual(t, first.port, second.port)
+}
+
+func Test_reservePort_ErrorWhenPortAlreadyReserved(t *testing.T) {
+	reservation, err := reservePort(DefaultConfig().Port(9878))
+	require.NoError(t, err)
+
+	_, err = reservePort(DefaultConfig().Port(9878))
+	assert.EqualError(t, err, "port 9878 is reserved by another process")
+	assert.ErrorIs(t, err, ErrPortInUse)
+
+	reservation.release()
+
+	reservation, err = reservePort(DefaultConfig().Port(9878))
+	require.NoError(t, err)
+	reservation.release()
+}
+
+func Test_reservePort_ErrorWhenPortAlreadyTaken(t *testing.T) {
+	listener, err := net.Listen("tcp", "localhost:9879")
+	require.NoError(t, err)
+
+	defer func() {
+		if err := listener.Close(); err != nil {
+			panic(err)
+		}
+	}()
+
+	_, err = reservePort(DefaultConfig().Port(9879))
+
+	assert.EqualError(t, err, "process already listening on port 9879")
+	assert.ErrorIs(t, err, ErrPortInUse)
+}
+
+func Test_reservePort_WithinRange(t *testing.T) {
+	first, err := reservePort(DefaultConfig().Port(0).PortRange(9880, 9881))
+	require.NoError(t, err)
+	defer first.release()
+
+	second, err := reservePort(DefaultConfig().Port(0).PortRange(9880, 9881))
+	require.NoError(t, err)
+	defer second.release()
+
+	assert.ElementsMatch(t, []uint32{9880, 9881}, []uint32{first.port, second.port})
+
+	_, err = reservePort(DefaultConfig().Port(0).PortRange(9880, 9881))
+	assert.EqualError(t, err, "no free port available in range 9880-9881")
+	assert.ErrorIs(t, err, ErrPortInUse)
+}
+
+func Test_reservePort_ErrorWhenRangeInvalid(t *testing.T) {
+	_, err := reservePort(DefaultConfig().Port(0).PortRange(9881, 9880))
+
+	assert.EqualError(t, err, "invalid port range 9881-9880")
+	assert.ErrorIs(t, err, ErrInvalidPortRange)
+}
+
+func Test_reservePort_ReleaseNilReservation(t *testing.T) {
+	var reservation *portReservation
+
+	assert.NotPanics(t, reservation.release)
+}
+
+func Test_portLockPath_DirectorySharedBetweenUsers(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the temporary directory is private to each user on Windows")
+	}
+
+	t.Setenv("TMPDIR", t.TempDir())
+
+	lockPath, err := portLockPath(9879)
+	require.NoError(t, err)
+
+	info, err := os.Stat(filepath.Dir(lockPath))
+	require.NoError(t, err)
+
+	assert.Equal(t, os.FileMode(0777), info.Mode().Perm())
+	assert.NotZero(t, info.Mode()&os.ModeSticky)
+}
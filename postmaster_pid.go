@@ -0,0 +1,70 @@
+package embeddedpostgres
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// postmasterPidFile holds the contents of the postmaster.pid file Postgres writes into its data directory while
+// running, see https://www.postgresql.org/docs/current/storage-file-layout.html
+type postmasterPidFile struct {
+	pid           int
+	dataDir       string
+	startTime     time.Time
+	port          uint32
+	socketDir     string
+	listenAddress string
+}
+
+// readPostmasterPidFile reads the postmaster.pid file of dataPath returning false when there is none.
+func readPostmasterPidFile(dataPath string) (postmasterPidFile, bool, error) {
+	pidFilePath := filepath.Join(dataPath, "postmaster.pid")
+
+	content, err := os.ReadFile(pidFilePath)
+	if os.IsNotExist(err) {
+		return postmasterPidFile{}, false, nil
+	}
+
+	if err != nil {
+		return postmasterPidFile{}, false, fmt.Errorf("unable to read %s: %w", pidFilePath, err)
+	}
+
+	lines := strings.Split(string(content), "\n")
+
+	pid, err := strconv.Atoi(strings.TrimSpace(lines[0]))
+	if err != nil {
+		return postmasterPidFile{}, false, fmt.Errorf("unable to parse pid from %s: %w", pidFilePath, err)
+	}
+
+	pidFile := postmasterPidFile{pid: pid}
+
+	if len(lines) > 1 {
+		pidFile.dataDir = strings.TrimSpace(lines[1])
+	}
+
+	if len(lines) > 2 {
+		if startTime, err := strconv.ParseInt(strings.TrimSpace(lines[2]), 10, 64); err == nil {
+			pidFile.startTime = time.Unix(startTime, 0)
+		}
+	}
+
+	if len(lines) > 3 {
+		if port, err := strconv.ParseUint(strings.TrimSpace(lines[3]), 10, 32); err == nil {
+			pidFile.port = uint32(port)
+		}
+	}
+
+	if len(lines) > 4 {
+		pidFile.socketDir = strings.TrimSpace(lines[4])
+	}
+
+	if len(lines) > 5 {
+		pidFile.listenAddress = strings.TrimSpace(lines[5])
+	}
+
+	return pidFile, true, nil
+}
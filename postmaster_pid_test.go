@@ -0,0 +1,63 @@
+package embeddedpostgres
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_readPostmasterPidFile(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "postmaster_pid_test")
+	require.NoError(t, err)
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	content := "4242\n/tmp/data\n1700000000\n5433\n/tmp\n*\n  5433001         0\nready   \n"
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "postmaster.pid"), []byte(content), 0600))
+
+	pidFile, exists, err := readPostmasterPidFile(tempDir)
+
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, postmasterPidFile{
+		pid:           4242,
+		dataDir:       "/tmp/data",
+		startTime:     time.Unix(1700000000, 0),
+		port:          5433,
+		socketDir:     "/tmp",
+		listenAddress: "*",
+	}, pidFile)
+}
+
+func Test_readPostmasterPidFile_NotExists(t *testing.T) {
+	_, exists, err := readPostmasterPidFile("/does-not-exist")
+
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func Test_readPostmasterPidFile_ErrorWhenInvalidPid(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "postmaster_pid_test")
+	require.NoError(t, err)
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "postmaster.pid"), []byte("not-a-pid\n"), 0600))
+
+	_, _, err = readPostmasterPidFile(tempDir)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "unable to parse pid from "+filepath.Join(tempDir, "postmaster.pid"))
+}
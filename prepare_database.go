@@ -3,7 +3,6 @@ package embeddedpostgres
 import (
 	"context"
 	"database/sql"
-	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -18,15 +17,95 @@ const (
 	fmtAfterError  = "%v happened after error: %w"
 )
 
-type initDatabase func(binaryExtractLocation, runtimePath, pgDataDir, username, password, locale string, encoding string, logger *os.File) error
-type createDatabase func(port uint32, username, password, database string) error
+// AuthMethod is a client authentication method written to pg_hba.conf by initdb.
+// See https://www.postgresql.org/docs/current/auth-methods.html
+type AuthMethod string
 
-func defaultInitDatabase(binaryExtractLocation, runtimePath, pgDataDir, username, password, locale string, encoding string, logger *os.File) error {
+// Authentication methods commonly used for local and host connections.
+const (
+	AuthTrust       = AuthMethod("trust")
+	AuthReject      = AuthMethod("reject")
+	AuthPassword    = AuthMethod("password")
+	AuthMD5         = AuthMethod("md5")
+	AuthScramSHA256 = AuthMethod("scram-sha-256")
+	AuthPeer        = AuthMethod("peer")
+)
+
+// initDBOptions holds the initdb options beyond the superuser, locale and encoding, see the matching Config methods.
+type initDBOptions struct {
+	dataChecksums  bool
+	walSegmentSize uint32
+	walDir         string
+	icuLocale      string
+	lcCollate      string
+	lcCtype        string
+	authLocal      AuthMethod
+	authHost       AuthMethod
+	noSync         bool
+	extraArgs      []string
+}
+
+func (o initDBOptions) args() ([]string, error) {
+	var args []string
+
+	if o.dataChecksums {
+		args = append(args, "--data-checksums")
+	}
+
+	if o.walSegmentSize != 0 {
+		args = append(args, fmt.Sprintf("--wal-segsize=%d", o.walSegmentSize))
+	}
+
+	if o.walDir != "" {
+		// initdb requires an absolute path as it links the data directory to it
+		walDir, err := filepath.Abs(o.walDir)
+		if err != nil {
+			return nil, fmt.Errorf("unable to resolve WAL directory %s with error: %w", o.walDir, err)
+		}
+
+		args = append(args, fmt.Sprintf("--waldir=%s", walDir))
+	}
+
+	if o.icuLocale != "" {
+		args = append(args, "--locale-provider=icu", fmt.Sprintf("--icu-locale=%s", o.icuLocale))
+	}
+
+	if o.lcCollate != "" {
+		args = append(args, fmt.Sprintf("--lc-collate=%s", o.lcCollate))
+	}
+
+	if o.lcCtype != "" {
+		args = append(args, fmt.Sprintf("--lc-ctype=%s", o.lcCtype))
+	}
+
+	if o.authLocal != "" {
+		args = append(args, fmt.Sprintf("--auth-local=%s", o.authLocal))
+	}
+
+	if o.authHost != "" {
+		args = append(args, fmt.Sprintf("--auth-host=%s", o.authHost))
+	}
+
+	if o.noSync {
+		args = append(args, "--no-sync")
+	}
+
+	return append(args, o.extraArgs...), nil
+}
+
+type initDatabase func(ctx context.Context, binaryExtractLocation, runtimePath, pgDataDir, username, password, locale string, encoding string, logger *os.File, runAs *osUser, options initDBOptions) error
+type createDatabase func(ctx context.Context, host string, port uint32, username, password, database string) error
+
+func defaultInitDatabase(ctx context.Context, binaryExtractLocation, runtimePath, pgDataDir, username, password, locale string, encoding string, logger *os.File, runAs *osUser, options initDBOptions) error {
 	passwordFile, err := createPasswordFile(runtimePath, password)
 	if err != nil {
 		return err
 	}
 
+	if err := runAs.chownAll(passwordFile); err != nil {
+		return err
+	}
+
 	args := []string{
 		"-A", "password",
 		"-U", username,
@@ -42,17 +121,29 @@ func defaultInitDatabase(binaryExtractLocation, runtimePath, pgDataDir, username
 		args = append(args, fmt.Sprintf("--encoding=%s", encoding))
 	}
 
+	optionArgs, err := options.args()
+	if err != nil {
+		return err
+	}
+
+	args = append(args, optionArgs...)
+
 	postgresInitDBBinary := filepath.Join(binaryExtractLocation, "bin/initdb")
-	postgresInitDBProcess := exec.Command(postgresInitDBBinary, args...)
+	postgresInitDBProcess := exec.CommandContext(ctx, postgresInitDBBinary, args...)
 	postgresInitDBProcess.Stderr = logger
 	postgresInitDBProcess.Stdout = logger
+	runAs.apply(postgresInitDBProcess)
 
 	if err = postgresInitDBProcess.Run(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
 		logContent, readLogsErr := readLogsOrTimeout(logger) // we want to preserve the original error
 		if readLogsErr != nil {
 			logContent = []byte(string(logContent) + " - " + readLogsErr.Error())
 		}
-		return fmt.Errorf("unable to init database using '%s': %w\n%s", postgresInitDBProcess.String(), err, string(logContent))
+		return &InitDBError{Command: postgresInitDBProcess.String(), Err: err, Log: string(logContent)}
 	}
 
 	if err = os.Remove(passwordFile); err != nil {
@@ -71,12 +162,12 @@ func createPasswordFile(runtimePath, password string) (string, error) {
 	return passwordFileLocation, nil
 }
 
-func defaultCreateDatabase(port uint32, username, password, database string) (err error) {
+func defaultCreateDatabase(ctx context.Context, host string, port uint32, username, password, database string) (err error) {
 	if database == "postgres" {
 		return nil
 	}
 
-	conn, err := openDatabaseConnection(port, username, password, "postgres")
+	conn, err := openDatabaseConnection(host, port, username, password, "postgres")
 	if err != nil {
 		return errorCustomDatabase(database, err)
 	}
@@ -86,7 +177,7 @@ func defaultCreateDatabase(port uint32, username, password, database string) (er
 		err = connectionClose(db, err)
 	}()
 
-	if _, err := db.Exec(fmt.Sprintf("CREATE DATABASE \"%s\"", database)); err != nil {
+	if _, err := db.ExecContext(ctx, fmt.Sprintf("CREATE DATABASE \"%s\"", database)); err != nil {
 		return errorCustomDatabase(database, err)
 	}
 
@@ -109,18 +200,19 @@ func connectionClose(db io.Closer, err error) error {
 	return err
 }
 
-func healthCheckDatabaseOrTimeout(config Config) error {
-	healthCheckSignal := make(chan bool)
+// startTimeoutLogLines is the number of log lines a StartTimeoutError carries.
+const startTimeoutLogLines = 20
 
-	defer close(healthCheckSignal)
+func healthCheckDatabaseOrTimeout(ctx context.Context, config Config, logger *syncedLogger) error {
+	healthCheckSignal := make(chan bool, 1)
 
-	timeout, cancelFunc := context.WithTimeout(context.Background(), config.startTimeout)
+	timeout, cancelFunc := context.WithTimeout(ctx, config.startTimeout)
 
 	defer cancelFunc()
 
 	go func() {
 		for timeout.Err() == nil {
-			if err := healthCheckDatabase(config.port, config.database, config.username, config.password); err != nil {
+			if err := healthCheckDatabase(timeout, config.connectionHost(), config.port, config.database, config.username, config.password); err != nil {
 				continue
 			}
 			healthCheckSignal <- true
@@ -133,12 +225,25 @@ func healthCheckDatabaseOrTimeout(config Config) error {
 	case <-healthCheckSignal:
 		return nil
 	case <-timeout.Done():
-		return errors.New("timed out waiting for database to become available")
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		return newStartTimeoutError(config, logger)
 	}
 }
 
-func healthCheckDatabase(port uint32, database, username, password string) (err error) {
-	conn, err := openDatabaseConnection(port, username, password, database)
+func newStartTimeoutError(config Config, logger *syncedLogger) *StartTimeoutError {
+	return &StartTimeoutError{
+		Timeout:       config.startTimeout,
+		Port:          config.port,
+		ConnectionURL: config.GetConnectionURL(),
+		Log:           logger.tail(startTimeoutLogLines),
+	}
+}
+
+func healthCheckDatabase(ctx context.Context, host string, port uint32, database, username, password string) (err error) {
+	conn, err := openDatabaseConnection(host, port, username, password, database)
 	if err != nil {
 		return err
 	}
@@ -148,15 +253,17 @@ func healthCheckDatabase(port uint32, database, username, password string) (err
 		err = connectionClose(db, err)
 	}()
 
-	if _, err := db.Query("SELECT 1"); err != nil {
+	rows, err := db.QueryContext(ctx, "SELECT 1")
+	if err != nil {
 		return err
 	}
 
-	return nil
+	return rows.Close()
 }
 
-func openDatabaseConnection(port uint32, username string, password string, database string) (*pq.Connector, error) {
-	conn, err := pq.NewConnector(fmt.Sprintf("host=localhost port=%d user=%s password=%s dbname=%s sslmode=disable",
+func openDatabaseConnection(host string, port uint32, username string, password string, database string) (*pq.Connector, error) {
+	conn, err := pq.NewConnector(fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
+		host,
 		port,
 		username,
 		password,
@@ -168,6 +275,21 @@ func openDatabaseConnection(port uint32, username string, password string, datab
 	return conn, nil
 }
 
+// withDatabase runs fn with a connection to database as the configured user.
+func withDatabase(config Config, database string, fn func(db *sql.DB) error) (err error) {
+	conn, err := openDatabaseConnection(config.connectionHost(), config.port, config.username, config.password, database)
+	if err != nil {
+		return err
+	}
+
+	db := sql.OpenDB(conn)
+	defer func() {
+		err = connectionClose(db, err)
+	}()
+
+	return fn(db)
+}
+
 func errorCustomDatabase(database string, err error) error {
-	return fmt.Errorf("unable to connect to create database with custom name %s with the following error: %s", database, err)
+	return &CreateDatabaseError{Database: database, Err: err}
 }
@@ -1,18 +1,22 @@
 package embeddedpostgres
 
 import (
+	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func Test_defaultInitDatabase_ErrorWhenCannotCreatePasswordFile(t *testing.T) {
-	err := defaultInitDatabase("path_not_exists", "path_not_exists", "path_not_exists", "Tom", "Beer", "", "", os.Stderr)
+	err := defaultInitDatabase(context.Background(), "path_not_exists", "path_not_exists", "path_not_exists", "Tom", "Beer", "", "", os.Stderr, nil, initDBOptions{})
 
 	assert.EqualError(t, err, "unable to write password file to path_not_exists/pwfile")
 }
@@ -49,7 +53,7 @@ func Test_defaultInitDatabase_ErrorWhenCannotStartInitDBProcess(t *testing.T) {
 
 	_, _ = logFile.Write([]byte("and here are the logs!"))
 
-	err = defaultInitDatabase(binTempDir, runtimeTempDir, filepath.Join(runtimeTempDir, "data"), "Tom", "Beer", "", "", logFile)
+	err = defaultInitDatabase(context.Background(), binTempDir, runtimeTempDir, filepath.Join(runtimeTempDir, "data"), "Tom", "Beer", "", "", logFile, nil, initDBOptions{})
 
 	assert.NotNil(t, err)
 	assert.Contains(t, err.Error(), fmt.Sprintf("unable to init database using '%s/bin/initdb -A password -U Tom -D %s/data --pwfile=%s/pwfile'",
@@ -72,7 +76,7 @@ func Test_defaultInitDatabase_ErrorInvalidLocaleSetting(t *testing.T) {
 		}
 	}()
 
-	err = defaultInitDatabase(tempDir, tempDir, filepath.Join(tempDir, "data"), "postgres", "postgres", "en_XY", "", os.Stderr)
+	err = defaultInitDatabase(context.Background(), tempDir, tempDir, filepath.Join(tempDir, "data"), "postgres", "postgres", "en_XY", "", os.Stderr, nil, initDBOptions{})
 
 	assert.NotNil(t, err)
 	assert.Contains(t, err.Error(), fmt.Sprintf("unable to init database using '%s/bin/initdb -A password -U postgres -D %s/data --pwfile=%s/pwfile --locale=en_XY'",
@@ -93,7 +97,7 @@ func Test_defaultInitDatabase_ErrorInvalidEncodingSetting(t *testing.T) {
 		}
 	}()
 
-	err = defaultInitDatabase(tempDir, tempDir, filepath.Join(tempDir, "data"), "postgres", "postgres", "", "invalid", os.Stderr)
+	err = defaultInitDatabase(context.Background(), tempDir, tempDir, filepath.Join(tempDir, "data"), "postgres", "postgres", "", "invalid", os.Stderr, nil, initDBOptions{})
 
 	assert.NotNil(t, err)
 	assert.Contains(t, err.Error(), fmt.Sprintf("unable to init database using '%s/bin/initdb -A password -U postgres -D %s/data --pwfile=%s/pwfile --encoding=invalid'",
@@ -131,8 +135,46 @@ func Test_defaultInitDatabase_PwFileRemoved(t *testing.T) {
 	assert.True(t, os.IsNotExist(err), "pwfile (%v) still exists after starting the db", pwFile)
 }
 
+func Test_initDBOptions_args(t *testing.T) {
+	walDir, err := filepath.Abs("wal")
+	assert.NoError(t, err)
+
+	args, err := DefaultConfig().
+		DataChecksums(true).
+		WALSegmentSize(64).
+		WALDir("wal").
+		ICULocale("en-US").
+		LCCollate("C").
+		LCCtype("en_US.UTF-8").
+		AuthLocal(AuthPeer).
+		AuthHost(AuthScramSHA256).
+		NoSync(true).
+		InitDBArgs("--no-instructions").
+		initDB.args()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"--data-checksums",
+		"--wal-segsize=64",
+		"--waldir=" + walDir,
+		"--locale-provider=icu",
+		"--icu-locale=en-US",
+		"--lc-collate=C",
+		"--lc-ctype=en_US.UTF-8",
+		"--auth-local=peer",
+		"--auth-host=scram-sha-256",
+		"--no-sync",
+		"--no-instructions",
+	}, args)
+
+	args, err = DefaultConfig().initDB.args()
+
+	assert.NoError(t, err)
+	assert.Empty(t, args)
+}
+
 func Test_defaultCreateDatabase_ErrorWhenSQLOpenError(t *testing.T) {
-	err := defaultCreateDatabase(1234, "user client_encoding=lol", "password", "database")
+	err := defaultCreateDatabase(context.Background(), "localhost", 1234, "user client_encoding=lol", "password", "database")
 
 	assert.EqualError(t, err, "unable to connect to create database with custom name database with the following error: client_encoding must be absent or 'UTF8'")
 }
@@ -165,17 +207,54 @@ func Test_defaultCreateDatabase_ErrorWhenQueryError(t *testing.T) {
 		}
 	}()
 
-	err := defaultCreateDatabase(9831, "postgres", "postgres", "b33r")
+	err := defaultCreateDatabase(context.Background(), "localhost", 9831, "postgres", "postgres", "b33r")
 
 	assert.EqualError(t, err, `unable to connect to create database with custom name b33r with the following error: pq: database "b33r" already exists`)
 }
 
 func Test_healthCheckDatabase_ErrorWhenSQLConnectingError(t *testing.T) {
-	err := healthCheckDatabase(1234, "tom client_encoding=lol", "more", "b33r")
+	err := healthCheckDatabase(context.Background(), "localhost", 1234, "tom client_encoding=lol", "more", "b33r")
 
 	assert.EqualError(t, err, "client_encoding must be absent or 'UTF8'")
 }
 
+func Test_healthCheckDatabaseOrTimeout_ErrorWhenTimedOut(t *testing.T) {
+	config := DefaultConfig().
+		Port(1234).
+		StartTimeout(200 * time.Millisecond)
+
+	logger, err := newSyncedLogger(t.TempDir(), nil)
+	require.NoError(t, err)
+
+	defer logger.file.Close()
+
+	_, err = logger.file.WriteString("LOG:  starting PostgreSQL\nFATAL:  could not create lock file\n")
+	require.NoError(t, err)
+
+	err = healthCheckDatabaseOrTimeout(context.Background(), config, logger)
+
+	assert.EqualError(t, err, "timed out waiting for database to become available\nLOG:  starting PostgreSQL\nFATAL:  could not create lock file")
+	assert.ErrorIs(t, err, ErrStartTimeout)
+
+	var timeoutErr *StartTimeoutError
+	require.ErrorAs(t, err, &timeoutErr)
+	assert.Equal(t, config.GetConnectionURL(), timeoutErr.ConnectionURL)
+	assert.Equal(t, uint32(1234), timeoutErr.Port)
+}
+
+func Test_healthCheckDatabaseOrTimeout_ErrorWhenContextCancelled(t *testing.T) {
+	config := DefaultConfig().
+		Port(1234).
+		StartTimeout(10 * time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	err := healthCheckDatabaseOrTimeout(ctx, config, nil)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
 type CloserWithoutErr struct{}
 
 func (c *CloserWithoutErr) Close() error {
@@ -269,3 +348,52 @@ func TestConnCloserWithErr(t *testing.T) {
 		})
 	}
 }
+
+func Test_InitDBOptions(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "embedded_postgres_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	walDir := filepath.Join(tempDir, "wal")
+	database := NewDatabase(DefaultConfig().
+		Port(9844).
+		RuntimePath(filepath.Join(tempDir, "runtime")).
+		DataChecksums(true).
+		WALDir(walDir).
+		ICULocale("en-US").
+		NoSync(true))
+
+	if err := database.Start(); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	db, err := sql.Open("postgres", database.GetConnectionURL()+"?sslmode=disable")
+	if err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	var dataChecksums, localeProvider string
+	if err := db.QueryRow("SELECT current_setting('data_checksums'), datlocprovider FROM pg_database WHERE datname = 'postgres'").
+		Scan(&dataChecksums, &localeProvider); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	assert.Equal(t, "on", dataChecksums)
+	assert.Equal(t, "i", localeProvider)
+	assert.DirExists(t, filepath.Join(walDir, "archive_status"))
+
+	if err := db.Close(); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	if err := database.Stop(); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+}
@@ -0,0 +1,35 @@
+//go:build !windows
+
+package embeddedpostgres
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// processExecutable returns the path of the executable a process is running, or false when there is no such process.
+func processExecutable(pid int) (string, bool, error) {
+	if err := syscall.Kill(pid, 0); errors.Is(err, syscall.ESRCH) {
+		return "", false, nil
+	}
+
+	if executable, err := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid)); err == nil {
+		return strings.TrimSuffix(executable, " (deleted)"), true, nil
+	}
+
+	output, err := exec.Command("ps", "-o", "comm=", "-p", fmt.Sprintf("%d", pid)).Output()
+	if err != nil {
+		// ps exits with an error when the process has gone in the meantime
+		if _, isExitErr := err.(*exec.ExitError); isExitErr {
+			return "", false, nil
+		}
+
+		return "", false, fmt.Errorf("unable to inspect process %d: %w", pid, err)
+	}
+
+	return strings.TrimSpace(string(output)), true, nil
+}
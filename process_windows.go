@@ -0,0 +1,27 @@
+//go:build windows
+
+package embeddedpostgres
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// processExecutable returns the image name of a running process, or false when there is no such process.
+// Windows does not expose the full executable path of other processes without additional privileges.
+func processExecutable(pid int) (string, bool, error) {
+	output, err := exec.Command("tasklist", "/FI", fmt.Sprintf("PID eq %d", pid), "/FO", "CSV", "/NH").Output()
+	if err != nil {
+		return "", false, fmt.Errorf("unable to inspect process %d: %w", pid, err)
+	}
+
+	records, err := csv.NewReader(strings.NewReader(string(output))).ReadAll()
+	if err != nil || len(records) == 0 || len(records[0]) < 2 {
+		// tasklist prints an informational message rather than a CSV row when nothing matches
+		return "", false, nil
+	}
+
+	return records[0][0], true, nil
+}
@@ -0,0 +1,130 @@
+package embeddedpostgres
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// clientEnv returns the environment of client processes such as psql, which connect to the database of config using
+// the PG* environment variables and find the bundled client binaries on the PATH. It also sets the POSTGRES_*
+// variables of the official Docker image for the benefit of init scripts written for it.
+func clientEnv(config Config) []string {
+	binDir := filepath.Join(config.binariesPath, "bin")
+
+	return append(os.Environ(),
+		fmt.Sprintf("PGHOST=%s", config.connectionHost()),
+		fmt.Sprintf("PGPORT=%d", config.port),
+		fmt.Sprintf("PGUSER=%s", config.username),
+		fmt.Sprintf("PGPASSWORD=%s", config.password),
+		fmt.Sprintf("PGDATABASE=%s", config.database),
+		fmt.Sprintf("POSTGRES_USER=%s", config.username),
+		fmt.Sprintf("POSTGRES_PASSWORD=%s", config.password),
+		fmt.Sprintf("POSTGRES_DB=%s", config.database),
+		fmt.Sprintf("PATH=%s%c%s", binDir, os.PathListSeparator, os.Getenv("PATH")),
+	)
+}
+
+// psqlCommand returns a command running the bundled psql with args, connected to the database of config. psql reads
+// no psqlrc and stops at the first error, returning a non-zero exit code.
+func psqlCommand(ctx context.Context, config Config, runAs *osUser, args ...string) *exec.Cmd {
+	psqlBinary := filepath.Join(config.binariesPath, "bin", "psql")
+
+	cmd := exec.CommandContext(ctx, psqlBinary, append([]string{"-X", "--no-password", "-v", "ON_ERROR_STOP=1"}, args...)...)
+	cmd.Env = clientEnv(config)
+	runAs.apply(cmd)
+
+	return cmd
+}
+
+// ExecOptions configures how ExecFile runs psql.
+type ExecOptions struct {
+	// Database to connect to, the configured Database when left empty.
+	Database string
+	// Role to connect as, the configured Username when left empty. The password is taken from the Roles list.
+	Role string
+	// Variables are set using psql -v before the file runs, for use as :name in SQL or \set in the script.
+	Variables map[string]string
+}
+
+// ExecResult holds the output of psql run by ExecFile or ExecSQL.
+type ExecResult struct {
+	Stdout string
+	Stderr string
+}
+
+// ExecFile runs the SQL file at path using the bundled psql, so that psql meta-commands such as \copy and \set can be
+// used. psql stops at the first error, which is returned as an ExecError naming the line psql reported. ExecFile can
+// also be called from the AfterStart and BeforeStop hooks.
+func (ep *EmbeddedPostgres) ExecFile(ctx context.Context, path string, opts ExecOptions) (ExecResult, error) {
+	return ep.exec(ctx, path, nil, opts)
+}
+
+// ExecSQL runs sql against the configured database using the bundled psql, see ExecFile.
+func (ep *EmbeddedPostgres) ExecSQL(ctx context.Context, sql string) (ExecResult, error) {
+	return ep.exec(ctx, "", strings.NewReader(sql), ExecOptions{})
+}
+
+func (ep *EmbeddedPostgres) exec(ctx context.Context, path string, stdin io.Reader, opts ExecOptions) (ExecResult, error) {
+	server, err := ep.snapshotServer()
+	if err != nil {
+		return ExecResult{}, err
+	}
+
+	file := path
+	if file == "" {
+		file = "-"
+	}
+
+	args := make([]string, 0, 2*len(opts.Variables)+2)
+
+	names := make([]string, 0, len(opts.Variables))
+	for name := range opts.Variables {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		args = append(args, "-v", fmt.Sprintf("%s=%s", name, opts.Variables[name]))
+	}
+
+	cmd := psqlCommand(ctx, server.config, server.runAs, append(args, "-f", file)...)
+	cmd.Stdin = stdin
+
+	if opts.Database != "" {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("PGDATABASE=%s", opts.Database))
+	}
+
+	if opts.Role != "" {
+		cmd.Env = append(cmd.Env,
+			fmt.Sprintf("PGUSER=%s", opts.Role),
+			fmt.Sprintf("PGPASSWORD=%s", server.config.rolePassword(opts.Role)))
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+
+	result := ExecResult{Stdout: stdout.String(), Stderr: stderr.String()}
+	if err != nil {
+		execErr := &ExecError{File: path, Err: err, Stderr: result.Stderr}
+
+		if matches := psqlErrorLine.FindAllStringSubmatch(result.Stderr, -1); len(matches) > 0 {
+			execErr.Line, _ = strconv.Atoi(matches[len(matches)-1][1])
+		}
+
+		return result, execErr
+	}
+
+	return result, nil
+}
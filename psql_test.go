@@ -0,0 +1,139 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// echoPsql stands in for psql, echoing its connection, arguments and input and failing when either mentions "fail".
+const echoPsql = `#!/bin/sh
+input=$(cat -)
+echo "$PGUSER:$PGPASSWORD@$PGDATABASE $*"
+echo "$input"
+case "$* $input" in
+*fail*) echo "psql:<stdin>:1: ERROR:  relation \"fail\" does not exist" >&2; exit 3 ;;
+esac
+`
+
+func psqlTestDatabase(t *testing.T, config Config) *EmbeddedPostgres {
+	database, _ := fixtureDatabase(t, config.Database("beer"), map[string]string{"psql": echoPsql})
+	database.state = StateRunning
+
+	return database
+}
+
+func Test_ExecFile(t *testing.T) {
+	database := psqlTestDatabase(t, DefaultConfig().
+		Roles(RoleSpec{Name: "app", Password: "secret", Login: true}))
+
+	result, err := database.ExecFile(context.Background(), "seed.sql", ExecOptions{
+		Database:  "orders",
+		Role:      "app",
+		Variables: map[string]string{"tenant": "acme", "batch": "7"},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, "app:secret@orders -X --no-password -v ON_ERROR_STOP=1 -v batch=7 -v tenant=acme -f seed.sql\n\n", result.Stdout)
+	assert.Empty(t, result.Stderr)
+}
+
+func Test_ExecSQL(t *testing.T) {
+	database := psqlTestDatabase(t, DefaultConfig())
+
+	result, err := database.ExecSQL(context.Background(), `\set answer 42`)
+
+	require.NoError(t, err)
+	assert.Equal(t, "postgres:postgres@beer -X --no-password -v ON_ERROR_STOP=1 -f -\n\\set answer 42\n", result.Stdout)
+}
+
+func Test_ExecSQL_ReturnsOutputOnError(t *testing.T) {
+	database := psqlTestDatabase(t, DefaultConfig())
+
+	result, err := database.ExecSQL(context.Background(), "SELECT * FROM fail")
+
+	assert.ErrorIs(t, err, ErrExecFailed)
+	assert.EqualError(t, err, "psql failed running SQL:1: exit status 3\npsql:<stdin>:1: ERROR:  relation \"fail\" does not exist\n")
+	assert.Contains(t, result.Stdout, "SELECT * FROM fail")
+	assert.Contains(t, result.Stderr, `relation "fail" does not exist`)
+
+	var execErr *ExecError
+	require.ErrorAs(t, err, &execErr)
+	assert.Equal(t, 1, execErr.Line)
+}
+
+func Test_ExecSQL_ErrorWhenNotStarted(t *testing.T) {
+	database := NewDatabase()
+
+	_, err := database.ExecSQL(context.Background(), "SELECT 1")
+
+	assert.ErrorIs(t, err, ErrServerNotStarted)
+}
+
+func Test_ExecSQL_DoesNotWaitForLifecycle(t *testing.T) {
+	database := psqlTestDatabase(t, DefaultConfig())
+	database.state = StateStarting
+
+	database.lifecycle.Lock()
+	defer database.lifecycle.Unlock()
+
+	_, err := database.ExecSQL(context.Background(), "SELECT 1")
+	assert.ErrorIs(t, err, ErrServerStarting)
+
+	database.setHookRunning(true)
+
+	result, err := database.ExecSQL(context.Background(), "SELECT 1")
+	require.NoError(t, err)
+	assert.Contains(t, result.Stdout, "SELECT 1")
+}
+
+func Test_ExecFile_CopiesFromFile(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "embedded_postgres_test")
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "beers.csv"), []byte("pilsner\nstout\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "seed.sql"), []byte(
+		"CREATE TABLE beers (name text);\n"+
+			"\\copy beers FROM '"+filepath.Join(tempDir, "beers.csv")+"' WITH (FORMAT csv)\n"+
+			"SELECT count(*) AS :\"column\" FROM beers;\n"), 0600))
+
+	database := NewDatabase(DefaultConfig().
+		Port(9851).
+		RuntimePath(filepath.Join(tempDir, "runtime")))
+
+	if err := database.Start(); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	result, err := database.ExecFile(context.Background(), filepath.Join(tempDir, "seed.sql"), ExecOptions{
+		Variables: map[string]string{"column": "beers"},
+	})
+	if err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	assert.Contains(t, result.Stdout, "beers")
+	assert.Contains(t, result.Stdout, "2")
+
+	_, err = database.ExecSQL(context.Background(), "SELECT 1;\nSELECT * FROM missing;")
+
+	var execErr *ExecError
+	require.ErrorAs(t, err, &execErr)
+	assert.Equal(t, 2, execErr.Line)
+
+	if err := database.Stop(); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+}
@@ -3,6 +3,7 @@ package embeddedpostgres
 import (
 	"archive/zip"
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
@@ -16,11 +17,12 @@ import (
 )
 
 // RemoteFetchStrategy provides a strategy to fetch a Postgres binary so that it is available for use.
-type RemoteFetchStrategy func() error
+// Implementations should give up and return the context error once ctx is done.
+type RemoteFetchStrategy func(ctx context.Context) error
 
 //nolint:funlen
 func defaultRemoteFetchStrategy(remoteFetchHost string, versionStrategy VersionStrategy, cacheLocator CacheLocator) RemoteFetchStrategy {
-	return func() error {
+	return func(ctx context.Context) error {
 		operatingSystem, architecture, version := versionStrategy()
 
 		jarDownloadURL := fmt.Sprintf("%s/io/zonky/test/postgres/embedded-postgres-binaries-%s-%s/%s/embedded-postgres-binaries-%s-%s-%s.jar",
@@ -32,40 +34,70 @@ func defaultRemoteFetchStrategy(remoteFetchHost string, versionStrategy VersionS
 			architecture,
 			version)
 
-		jarDownloadResponse, err := http.Get(jarDownloadURL)
+		jarDownloadResponse, err := httpGet(ctx, jarDownloadURL)
 		if err != nil {
-			return fmt.Errorf("unable to connect to %s", remoteFetchHost)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			return &DownloadError{URL: jarDownloadURL, Err: err}
 		}
 
 		defer closeBody(jarDownloadResponse)()
 
 		if jarDownloadResponse.StatusCode != http.StatusOK {
-			return fmt.Errorf("no version found matching %s", version)
+			return &VersionNotFoundError{Version: version, URL: jarDownloadURL}
 		}
 
 		jarBodyBytes, err := io.ReadAll(jarDownloadResponse.Body)
 		if err != nil {
-			return errorFetchingPostgres(err)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			return &DownloadError{URL: jarDownloadURL, Err: err}
 		}
 
-		shaDownloadURL := fmt.Sprintf("%s.sha256", jarDownloadURL)
-		shaDownloadResponse, err := http.Get(shaDownloadURL)
+		finishChecksum := phaseRecorderFromContext(ctx).begin(PhaseChecksum)
+		err = verifyChecksum(ctx, jarDownloadURL, jarBodyBytes)
+		finishChecksum(err)
+
 		if err != nil {
-			return fmt.Errorf("download sha256 from %s failed: %w", shaDownloadURL, err)
+			return err
 		}
-		defer closeBody(shaDownloadResponse)()
-
-		if err == nil && shaDownloadResponse.StatusCode == http.StatusOK {
-			if shaBodyBytes, err := io.ReadAll(shaDownloadResponse.Body); err == nil {
-				jarChecksum := sha256.Sum256(jarBodyBytes)
-				if !bytes.Equal(shaBodyBytes, []byte(hex.EncodeToString(jarChecksum[:]))) {
-					return errors.New("downloaded checksums do not match")
-				}
+
+		return decompressResponse(jarBodyBytes, jarDownloadResponse.ContentLength, cacheLocator, jarDownloadURL)
+	}
+}
+
+// verifyChecksum compares the jar against its published sha256 checksum, when there is one.
+func verifyChecksum(ctx context.Context, jarDownloadURL string, jarBodyBytes []byte) error {
+	shaDownloadURL := fmt.Sprintf("%s.sha256", jarDownloadURL)
+	shaDownloadResponse, err := httpGet(ctx, shaDownloadURL)
+	if err != nil {
+		return &DownloadError{URL: shaDownloadURL, Err: err}
+	}
+	defer closeBody(shaDownloadResponse)()
+
+	if shaDownloadResponse.StatusCode == http.StatusOK {
+		if shaBodyBytes, err := io.ReadAll(shaDownloadResponse.Body); err == nil {
+			jarChecksum := sha256.Sum256(jarBodyBytes)
+			if actual := hex.EncodeToString(jarChecksum[:]); !bytes.Equal(shaBodyBytes, []byte(actual)) {
+				return &ChecksumMismatchError{URL: jarDownloadURL, Expected: string(shaBodyBytes), Actual: actual}
 			}
 		}
+	}
 
-		return decompressResponse(jarBodyBytes, jarDownloadResponse.ContentLength, cacheLocator, jarDownloadURL)
+	return nil
+}
+
+func httpGet(ctx context.Context, url string) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
 	}
+
+	return http.DefaultClient.Do(request)
 }
 
 func closeBody(resp *http.Response) func() {
@@ -89,7 +121,7 @@ func decompressResponse(bodyBytes []byte, contentLength int64, cacheLocator Cach
 	}
 	zipReader, err := zip.NewReader(bytes.NewReader(bodyBytes), size)
 	if err != nil {
-		return errorFetchingPostgres(err)
+		return &DownloadError{URL: downloadURL, Err: err}
 	}
 
 	cacheLocation, _ := cacheLocator()
@@ -109,7 +141,7 @@ func decompressResponse(bodyBytes []byte, contentLength int64, cacheLocator Cach
 		}
 	}
 
-	return fmt.Errorf("error fetching postgres: cannot find binary in archive retrieved from %s", downloadURL)
+	return &DownloadError{URL: downloadURL, Err: errors.New("cannot find binary in archive")}
 }
 
 func decompressSingleFile(file *zip.File, cacheLocation string) error {
@@ -161,9 +193,5 @@ func decompressSingleFile(file *zip.File, cacheLocation string) error {
 }
 
 func errorExtractingPostgres(err error) error {
-	return fmt.Errorf("unable to extract postgres archive: %s", err)
-}
-
-func errorFetchingPostgres(err error) error {
-	return fmt.Errorf("error fetching postgres: %s", err)
+	return &ExtractError{Err: err}
 }
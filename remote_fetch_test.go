@@ -2,8 +2,10 @@ package embeddedpostgres
 
 import (
 	"archive/zip"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"github.com/stretchr/testify/require"
 	"io"
 	"net/http"
@@ -22,9 +24,13 @@ func Test_defaultRemoteFetchStrategy_ErrorWhenHttpGet(t *testing.T) {
 		testVersionStrategy(),
 		testCacheLocator())
 
-	err := remoteFetchStrategy()
+	err := remoteFetchStrategy(context.Background())
 
-	assert.EqualError(t, err, "unable to connect to http://localhost:1234/maven2")
+	var downloadErr *DownloadError
+	assert.ErrorIs(t, err, ErrDownloadFailed)
+	assert.True(t, errors.As(err, &downloadErr))
+	assert.Equal(t, testJarURL("http://localhost:1234/maven2"), downloadErr.URL)
+	assert.Contains(t, err.Error(), "error fetching postgres from "+testJarURL("http://localhost:1234/maven2")+": ")
 }
 
 func Test_defaultRemoteFetchStrategy_ErrorWhenHttpStatusNot200(t *testing.T) {
@@ -37,9 +43,38 @@ func Test_defaultRemoteFetchStrategy_ErrorWhenHttpStatusNot200(t *testing.T) {
 		testVersionStrategy(),
 		testCacheLocator())
 
-	err := remoteFetchStrategy()
+	err := remoteFetchStrategy(context.Background())
 
 	assert.EqualError(t, err, "no version found matching 1.2.3")
+	assert.ErrorIs(t, err, ErrVersionNotFound)
+
+	var versionErr *VersionNotFoundError
+	assert.True(t, errors.As(err, &versionErr))
+	assert.Equal(t, PostgresVersion("1.2.3"), versionErr.Version)
+	assert.Equal(t, testJarURL(server.URL), versionErr.URL)
+}
+
+func Test_defaultRemoteFetchStrategy_ErrorWhenContextCancelled(t *testing.T) {
+	requestReceived := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(requestReceived)
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	remoteFetchStrategy := defaultRemoteFetchStrategy(server.URL+"/maven2",
+		testVersionStrategy(),
+		testCacheLocator())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-requestReceived
+		cancel()
+	}()
+
+	err := remoteFetchStrategy(ctx)
+
+	assert.ErrorIs(t, err, context.Canceled)
 }
 
 func Test_defaultRemoteFetchStrategy_ErrorWhenBodyReadIssue(t *testing.T) {
@@ -52,9 +87,11 @@ func Test_defaultRemoteFetchStrategy_ErrorWhenBodyReadIssue(t *testing.T) {
 		testVersionStrategy(),
 		testCacheLocator())
 
-	err := remoteFetchStrategy()
+	err := remoteFetchStrategy(context.Background())
 
-	assert.EqualError(t, err, "error fetching postgres: unexpected EOF")
+	assert.EqualError(t, err, "error fetching postgres from "+testJarURL(server.URL+"/maven2")+": unexpected EOF")
+	assert.ErrorIs(t, err, ErrDownloadFailed)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
 }
 
 func Test_defaultRemoteFetchStrategy_ErrorWhenCannotUnzipSubFile(t *testing.T) {
@@ -70,9 +107,10 @@ func Test_defaultRemoteFetchStrategy_ErrorWhenCannotUnzipSubFile(t *testing.T) {
 		testVersionStrategy(),
 		testCacheLocator())
 
-	err := remoteFetchStrategy()
+	err := remoteFetchStrategy(context.Background())
 
-	assert.EqualError(t, err, "error fetching postgres: zip: not a valid zip file")
+	assert.EqualError(t, err, "error fetching postgres from "+testJarURL(server.URL+"/maven2")+": zip: not a valid zip file")
+	assert.ErrorIs(t, err, zip.ErrFormat)
 }
 
 func Test_defaultRemoteFetchStrategy_ErrorWhenCannotUnzip(t *testing.T) {
@@ -92,9 +130,10 @@ func Test_defaultRemoteFetchStrategy_ErrorWhenCannotUnzip(t *testing.T) {
 		testVersionStrategy(),
 		testCacheLocator())
 
-	err := remoteFetchStrategy()
+	err := remoteFetchStrategy(context.Background())
 
-	assert.EqualError(t, err, "error fetching postgres: zip: not a valid zip file")
+	assert.EqualError(t, err, "error fetching postgres from "+testJarURL(server.URL+"/maven2")+": zip: not a valid zip file")
+	assert.ErrorIs(t, err, zip.ErrFormat)
 }
 
 func Test_defaultRemoteFetchStrategy_ErrorWhenNoSubTarArchive(t *testing.T) {
@@ -116,9 +155,10 @@ func Test_defaultRemoteFetchStrategy_ErrorWhenNoSubTarArchive(t *testing.T) {
 		testVersionStrategy(),
 		testCacheLocator())
 
-	err := remoteFetchStrategy()
+	err := remoteFetchStrategy(context.Background())
 
-	assert.EqualError(t, err, "error fetching postgres: cannot find binary in archive retrieved from "+server.URL+"/maven2/io/zonky/test/postgres/embedded-postgres-binaries-darwin-amd64/1.2.3/embedded-postgres-binaries-darwin-amd64-1.2.3.jar")
+	assert.EqualError(t, err, "error fetching postgres from "+testJarURL(server.URL+"/maven2")+": cannot find binary in archive")
+	assert.ErrorIs(t, err, ErrDownloadFailed)
 }
 
 func Test_defaultRemoteFetchStrategy_ErrorWhenCannotExtractSubArchive(t *testing.T) {
@@ -147,7 +187,7 @@ func Test_defaultRemoteFetchStrategy_ErrorWhenCannotExtractSubArchive(t *testing
 			return filepath.FromSlash("/invalid"), false
 		})
 
-	err := remoteFetchStrategy()
+	err := remoteFetchStrategy(context.Background())
 
 	assert.Regexp(t, "^unable to extract postgres archive:.+$", err)
 }
@@ -187,7 +227,7 @@ func Test_defaultRemoteFetchStrategy_ErrorWhenCannotCreateCacheDirectory(t *test
 			return cacheLocation, false
 		})
 
-	err := remoteFetchStrategy()
+	err := remoteFetchStrategy(context.Background())
 
 	assert.Regexp(t, "^unable to extract postgres archive:.+$", err)
 }
@@ -224,7 +264,7 @@ func Test_defaultRemoteFetchStrategy_ErrorWhenCannotCreateSubArchiveFile(t *test
 			return "/\\000", false
 		})
 
-	err := remoteFetchStrategy()
+	err := remoteFetchStrategy(context.Background())
 
 	assert.Regexp(t, "^unable to extract postgres archive:.+$", err)
 }
@@ -262,9 +302,15 @@ func Test_defaultRemoteFetchStrategy_ErrorWhenSHA256NotMatch(t *testing.T) {
 			return cacheLocation, false
 		})
 
-	err := remoteFetchStrategy()
+	err := remoteFetchStrategy(context.Background())
 
 	assert.EqualError(t, err, "downloaded checksums do not match")
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+
+	var checksumErr *ChecksumMismatchError
+	assert.True(t, errors.As(err, &checksumErr))
+	assert.Equal(t, "literallyN3verGonnaWork", checksumErr.Expected)
+	assert.Len(t, checksumErr.Actual, 64)
 }
 
 func Test_defaultRemoteFetchStrategy(t *testing.T) {
@@ -301,7 +347,7 @@ func Test_defaultRemoteFetchStrategy(t *testing.T) {
 			return cacheLocation, false
 		})
 
-	err := remoteFetchStrategy()
+	err := remoteFetchStrategy(context.Background())
 
 	assert.NoError(t, err)
 	assert.FileExists(t, cacheLocation)
@@ -354,7 +400,7 @@ func Test_defaultRemoteFetchStrategyWithExistingDownload(t *testing.T) {
 		})
 
 	// call it the remoteFetchStrategy(). The output location should be empty and a new file created
-	err = remoteFetchStrategy()
+	err = remoteFetchStrategy(context.Background())
 	assert.NoError(t, err)
 	assert.FileExists(t, cacheLocation)
 	out1, err := os.ReadFile(cacheLocation)
@@ -364,7 +410,7 @@ func Test_defaultRemoteFetchStrategyWithExistingDownload(t *testing.T) {
 	assert.NoError(t, err)
 
 	// call the remoteFetchStrategy() again, this time the file should be overwritten
-	err = remoteFetchStrategy()
+	err = remoteFetchStrategy(context.Background())
 	assert.NoError(t, err)
 	assert.FileExists(t, cacheLocation)
 
@@ -412,7 +458,7 @@ func Test_defaultRemoteFetchStrategy_whenContentLengthNotSet(t *testing.T) {
 			return cacheLocation, false
 		})
 
-	err = remoteFetchStrategy()
+	err = remoteFetchStrategy(context.Background())
 
 	assert.NoError(t, err)
 	assert.FileExists(t, cacheLocation)
@@ -0,0 +1,220 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// RoleSpec describes a role that Start creates before the Databases, or updates when it already exists, so that
+// applications can connect with fewer privileges than the superuser.
+type RoleSpec struct {
+	Name string
+	// Password is required for the role to connect, as initdb configures password authentication by default.
+	Password    string
+	Login       bool
+	CreateDB    bool
+	Replication bool
+	// MemberOf lists the roles this role is granted membership of.
+	MemberOf []string
+	// Grants lists the privileges of the role in each of its databases.
+	Grants []RoleGrant
+}
+
+// RoleGrant gives a role privileges in a database, applied after the Databases have been created.
+type RoleGrant struct {
+	Database string
+	// DatabasePrivileges are granted on the database itself, CONNECT when left empty.
+	DatabasePrivileges []string
+	// Schema holds the tables and sequences the privileges below apply to, public when left empty.
+	Schema string
+	// TablePrivileges, such as SELECT or INSERT, are granted on the existing tables of Schema and by default on the
+	// tables created in it later.
+	TablePrivileges []string
+	// SequencePrivileges, such as USAGE, are granted on the existing sequences of Schema and by default on the sequences
+	// created in it later.
+	SequencePrivileges []string
+	// ForRole is the role whose future tables and sequences the default privileges apply to, typically the role running
+	// the migrations. When left empty it is the owner of the database if declared in Databases, or else the superuser.
+	ForRole string
+}
+
+var privilegePattern = regexp.MustCompile(`^[A-Za-z ]+$`)
+
+func (r RoleSpec) attributes() string {
+	attributes := []string{"NOLOGIN", "NOCREATEDB", "NOREPLICATION"}
+
+	if r.Login {
+		attributes[0] = "LOGIN"
+	}
+
+	if r.CreateDB {
+		attributes[1] = "CREATEDB"
+	}
+
+	if r.Replication {
+		attributes[2] = "REPLICATION"
+	}
+
+	if r.Password != "" {
+		attributes = append(attributes, "PASSWORD", pq.QuoteLiteral(r.Password))
+	}
+
+	return strings.Join(attributes, " ")
+}
+
+func (r RoleSpec) memberships() []string {
+	statements := make([]string, 0, len(r.MemberOf))
+	for _, group := range r.MemberOf {
+		statements = append(statements, fmt.Sprintf("GRANT %s TO %s", pq.QuoteIdentifier(group), pq.QuoteIdentifier(r.Name)))
+	}
+
+	return statements
+}
+
+func (g RoleGrant) statements(role, databaseOwner string) ([]string, error) {
+	databasePrivileges := g.DatabasePrivileges
+	if len(databasePrivileges) == 0 {
+		databasePrivileges = []string{"CONNECT"}
+	}
+
+	schema := g.Schema
+	if schema == "" {
+		schema = "public"
+	}
+
+	forRole := g.ForRole
+	if forRole == "" {
+		forRole = databaseOwner
+	}
+
+	grantee := pq.QuoteIdentifier(role)
+	quotedSchema := pq.QuoteIdentifier(schema)
+
+	privileges, err := joinPrivileges(databasePrivileges)
+	if err != nil {
+		return nil, err
+	}
+
+	statements := []string{fmt.Sprintf("GRANT %s ON DATABASE %s TO %s", privileges, pq.QuoteIdentifier(g.Database), grantee)}
+
+	if len(g.TablePrivileges) == 0 && len(g.SequencePrivileges) == 0 {
+		return statements, nil
+	}
+
+	statements = append(statements, fmt.Sprintf("GRANT USAGE ON SCHEMA %s TO %s", quotedSchema, grantee))
+
+	defaultPrivileges := "ALTER DEFAULT PRIVILEGES"
+	if forRole != "" {
+		defaultPrivileges += " FOR ROLE " + pq.QuoteIdentifier(forRole)
+	}
+
+	for _, objects := range []struct {
+		kind       string
+		privileges []string
+	}{
+		{"TABLES", g.TablePrivileges},
+		{"SEQUENCES", g.SequencePrivileges},
+	} {
+		if len(objects.privileges) == 0 {
+			continue
+		}
+
+		privileges, err := joinPrivileges(objects.privileges)
+		if err != nil {
+			return nil, err
+		}
+
+		statements = append(statements,
+			fmt.Sprintf("GRANT %s ON ALL %s IN SCHEMA %s TO %s", privileges, objects.kind, quotedSchema, grantee),
+			fmt.Sprintf("%s IN SCHEMA %s GRANT %s ON %s TO %s", defaultPrivileges, quotedSchema, privileges, objects.kind, grantee))
+	}
+
+	return statements, nil
+}
+
+func joinPrivileges(privileges []string) (string, error) {
+	for _, privilege := range privileges {
+		if !privilegePattern.MatchString(privilege) {
+			return "", fmt.Errorf("invalid privilege %q", privilege)
+		}
+	}
+
+	return strings.Join(privileges, ", "), nil
+}
+
+// createRoles creates every configured role that does not exist yet and updates the attributes and password of those
+// that do, followed by their memberships.
+func createRoles(ctx context.Context, config Config) error {
+	return withDatabase(config, "postgres", func(db *sql.DB) error {
+		for _, role := range config.roles {
+			var exists bool
+			if err := db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = $1)", role.Name).Scan(&exists); err != nil {
+				return &CreateRoleError{Role: role.Name, Err: err}
+			}
+
+			statement := "CREATE ROLE"
+			if exists {
+				statement = "ALTER ROLE"
+			}
+
+			if _, err := db.ExecContext(ctx, fmt.Sprintf("%s %s %s", statement, pq.QuoteIdentifier(role.Name), role.attributes())); err != nil {
+				return &CreateRoleError{Role: role.Name, Err: err}
+			}
+		}
+
+		for _, role := range config.roles {
+			for _, statement := range role.memberships() {
+				if _, err := db.ExecContext(ctx, statement); err != nil {
+					return &CreateRoleError{Role: role.Name, Err: err}
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// grantRoles applies the grants of every configured role, connecting to each database in turn as default privileges
+// only apply to the database they are altered in.
+func grantRoles(ctx context.Context, config Config) error {
+	owners := map[string]string{}
+	for _, database := range config.databases {
+		owners[database.Name] = database.Owner
+	}
+
+	for _, role := range config.roles {
+		for _, grant := range role.Grants {
+			statements, err := grant.statements(role.Name, owners[grant.Database])
+			if err != nil {
+				return &CreateRoleError{Role: role.Name, Err: err}
+			}
+
+			if err := withDatabase(config, grant.Database, func(db *sql.DB) error {
+				for _, statement := range statements {
+					if _, err := db.ExecContext(ctx, statement); err != nil {
+						return err
+					}
+				}
+
+				return nil
+			}); err != nil {
+				return &CreateRoleError{Role: role.Name, Err: err}
+			}
+		}
+	}
+
+	return nil
+}
+
+// GetRoleConnectionURL returns the connection URL for the named database as one of the Roles, using its password.
+func (ep *EmbeddedPostgres) GetRoleConnectionURL(role, database string) string {
+	ep.stateMu.Lock()
+	defer ep.stateMu.Unlock()
+
+	return ep.config.GetRoleConnectionURL(role, database)
+}
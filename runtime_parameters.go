@@ -0,0 +1,227 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var parameterNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// Reload applies run-time parameters to the running Postgres process without restarting it. The parameters are
+// persisted with ALTER SYSTEM before the configuration is reloaded with pg_ctl reload.
+//
+// ALTER SYSTEM writes postgresql.auto.conf in the data directory, so the parameters are not undone by Stop: every later
+// Start that reuses the DataPath keeps them until they are changed again or the data directory is removed.
+//
+// Reload returns the names of all parameters that have been changed but only take effect once Postgres is restarted,
+// for example shared_buffers. Parameters set through StartParameters take precedence over ALTER SYSTEM and can only be
+// changed using Restart. Reload can also be called from the AfterStart and BeforeStop hooks.
+func (ep *EmbeddedPostgres) Reload(parameters map[string]string) (pendingRestart []string, err error) {
+	server, err := ep.snapshotServer()
+	if err != nil {
+		return nil, err
+	}
+
+	config := server.config
+
+	if err := validateReloadParameters(config, parameters); err != nil {
+		return nil, err
+	}
+
+	ctx, cancelFunc := context.WithTimeout(context.Background(), config.startTimeout)
+	defer cancelFunc()
+
+	conn, err := openDatabaseConnection(config.connectionHost(), config.port, config.username, config.password, "postgres")
+	if err != nil {
+		return nil, err
+	}
+
+	db := sql.OpenDB(conn)
+	defer func() {
+		err = connectionClose(db, err)
+	}()
+
+	// every query uses a new session, which sees the configuration of the postmaster rather than that of an older session
+	db.SetMaxIdleConns(0)
+
+	var loadTime time.Time
+	if err := db.QueryRowContext(ctx, "SELECT pg_conf_load_time()").Scan(&loadTime); err != nil {
+		return nil, err
+	}
+
+	if err := alterSystem(ctx, db, parameters); err != nil {
+		return nil, err
+	}
+
+	if err := pgCtlReload(ctx, server); err != nil {
+		return nil, err
+	}
+
+	if err := waitForConfigurationReload(ctx, db, loadTime); err != nil {
+		return nil, err
+	}
+
+	if err := server.logger.flush(); err != nil {
+		return nil, err
+	}
+
+	return pendingRestartParameters(ctx, db)
+}
+
+// Restart stops and starts the Postgres process again, keeping the data directory, runtime directory and binaries.
+// The given parameters replace the StartParameters used so far, when nil the current StartParameters are kept.
+//
+// Restart returns the names of any parameters that still require a restart to take effect, which is only expected
+// when the configuration files are changed concurrently.
+func (ep *EmbeddedPostgres) Restart(parameters map[string]string) (pendingRestart []string, err error) {
+	if err := ep.beginTransition(StateStopping, nil, StateRunning); err != nil {
+		return nil, err
+	}
+
+	ep.lifecycle.Lock()
+	defer ep.lifecycle.Unlock()
+
+	if err := stopPostgres(context.Background(), ep); err != nil && !isStopTimeout(err) {
+		ep.endTransition(StateRunning)
+		return nil, err
+	}
+
+	// a concurrent Stop aborts the start in the same way as it does for Start
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := ep.beginTransition(StateStarting, cancel, StateStopping); err != nil {
+		return nil, err
+	}
+
+	if parameters != nil {
+		ep.stateMu.Lock()
+		ep.config.startParameters = parameters
+		ep.stateMu.Unlock()
+	}
+
+	if err := ep.startProcess(ctx); err != nil {
+		ep.endTransition(StateFailed)
+		return nil, err
+	}
+
+	if err := healthCheckDatabaseOrTimeout(ctx, ep.config, ep.syncedLogger); err != nil {
+		ep.endTransition(StateFailed)
+
+		if stopErr := stopPostgres(context.Background(), ep); stopErr != nil {
+			return nil, fmt.Errorf("unable to stop database caused by error %w", err)
+		}
+
+		return nil, err
+	}
+
+	ep.endTransition(StateRunning)
+
+	if err := ep.syncedLogger.flush(); err != nil {
+		return nil, err
+	}
+
+	conn, err := openDatabaseConnection(ep.config.connectionHost(), ep.config.port, ep.config.username, ep.config.password, "postgres")
+	if err != nil {
+		return nil, err
+	}
+
+	db := sql.OpenDB(conn)
+	defer func() {
+		err = connectionClose(db, err)
+	}()
+
+	return pendingRestartParameters(ctx, db)
+}
+
+func validateReloadParameters(config Config, parameters map[string]string) error {
+	for name := range parameters {
+		if !parameterNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid parameter name %q", name)
+		}
+
+		if _, ok := config.startParameters[name]; ok {
+			return fmt.Errorf("parameter %s is set through StartParameters and can only be changed using Restart", name)
+		}
+	}
+
+	return nil
+}
+
+func alterSystem(ctx context.Context, db *sql.DB, parameters map[string]string) error {
+	for name, value := range parameters {
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER SYSTEM SET %s = %s", name, pq.QuoteLiteral(value))); err != nil {
+			return fmt.Errorf("unable to set parameter %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func pgCtlReload(ctx context.Context, server serverSnapshot) error {
+	postgresBinary := filepath.Join(server.config.binariesPath, "bin/pg_ctl")
+	postgresProcess := exec.CommandContext(ctx, postgresBinary, "reload", "-D", server.config.dataPath)
+	postgresProcess.Stdout = server.logger.file
+	postgresProcess.Stderr = server.logger.file
+	server.runAs.apply(postgresProcess)
+
+	if err := postgresProcess.Run(); err != nil {
+		return fmt.Errorf("could not reload postgres using %s: %w", postgresProcess.String(), err)
+	}
+
+	return nil
+}
+
+// waitForConfigurationReload waits until new sessions report a configuration load time after previousLoadTime,
+// as the postmaster reloads its configuration asynchronously after receiving SIGHUP.
+func waitForConfigurationReload(ctx context.Context, db *sql.DB, previousLoadTime time.Time) error {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		var loadTime time.Time
+		if err := db.QueryRowContext(ctx, "SELECT pg_conf_load_time()").Scan(&loadTime); err != nil {
+			return err
+		}
+
+		if loadTime.After(previousLoadTime) {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.New("timed out waiting for postgres to reload its configuration")
+		case <-ticker.C:
+		}
+	}
+}
+
+func pendingRestartParameters(ctx context.Context, db *sql.DB) ([]string, error) {
+	rows, err := db.QueryContext(ctx, "SELECT name FROM pg_settings WHERE pending_restart ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var pendingRestart []string
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+
+		pendingRestart = append(pendingRestart, name)
+	}
+
+	return pendingRestart, rows.Err()
+}
@@ -0,0 +1,101 @@
+package embeddedpostgres
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ReloadAndRestart_ErrorWhenNotStarted(t *testing.T) {
+	database := NewDatabase()
+
+	_, err := database.Reload(map[string]string{"work_mem": "64MB"})
+	assert.ErrorIs(t, err, ErrServerNotStarted)
+
+	_, err = database.Restart(nil)
+	assert.ErrorIs(t, err, ErrServerNotStarted)
+}
+
+func Test_Reload_ErrorWhenInvalidParameterName(t *testing.T) {
+	database := NewDatabase()
+	database.state = StateRunning
+
+	_, err := database.Reload(map[string]string{"work_mem = 1; DROP TABLE x": "64MB"})
+
+	assert.EqualError(t, err, `invalid parameter name "work_mem = 1; DROP TABLE x"`)
+}
+
+func Test_Reload_ErrorWhenParameterSetAsStartParameter(t *testing.T) {
+	database := NewDatabase(DefaultConfig().StartParameters(map[string]string{"work_mem": "32MB"}))
+	database.state = StateRunning
+
+	_, err := database.Reload(map[string]string{"work_mem": "64MB"})
+
+	assert.EqualError(t, err, "parameter work_mem is set through StartParameters and can only be changed using Restart")
+}
+
+func Test_Reload(t *testing.T) {
+	database := NewDatabase(DefaultConfig().Port(9837))
+	if err := database.Start(); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	pendingRestart, err := database.Reload(map[string]string{
+		"statement_timeout": "1234",
+		"shared_buffers":    "64MB",
+	})
+	if err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	assert.Equal(t, []string{"shared_buffers"}, pendingRestart)
+	assert.Equal(t, "1234ms", showParameter(t, database, "statement_timeout"))
+
+	pendingRestart, err = database.Restart(nil)
+	if err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	assert.Empty(t, pendingRestart)
+	assert.Equal(t, "64MB", showParameter(t, database, "shared_buffers"))
+
+	if err := database.Stop(); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+}
+
+func Test_Restart_WithNewParameters(t *testing.T) {
+	database := NewDatabase(DefaultConfig().
+		Port(9838).
+		StartParameters(map[string]string{"work_mem": "4MB"}))
+	if err := database.Start(); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	if _, err := database.Restart(map[string]string{"work_mem": "16MB"}); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	assert.Equal(t, "16MB", showParameter(t, database, "work_mem"))
+
+	if err := database.Stop(); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+}
+
+func showParameter(t *testing.T, database *EmbeddedPostgres, name string) string {
+	db, err := sql.Open("postgres", database.GetConnectionURL()+"?sslmode=disable")
+	if err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	defer db.Close()
+
+	var value string
+	if err := db.QueryRow("SHOW " + name).Scan(&value); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	return value
+}
@@ -0,0 +1,107 @@
+package embeddedpostgres
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ownershipMarker is the file written into every runtime directory created by embedded-postgres. Start only deletes an
+// existing runtime directory when it holds the marker, so that a mistyped RuntimePath cannot wipe unrelated files.
+const ownershipMarker = ".embedded-postgres"
+
+// ownerPidFile records the PID of the Go process that created the runtime directory next to the ownership marker, so
+// that a server left running on it is only treated as orphaned once that process has exited.
+const ownerPidFile = ".embedded-postgres.pid"
+
+// cleanRuntimePath deletes the runtime directory after checking that it was created by embedded-postgres.
+func (ep *EmbeddedPostgres) cleanRuntimePath() error {
+	runtimePath := ep.config.runtimePath
+
+	if !ep.config.forceRuntimePathCleanup && !ep.defaultRuntimePath {
+		owned, err := runtimePathOwned(runtimePath)
+		if err != nil {
+			return err
+		}
+
+		if !owned {
+			return &RuntimePathNotOwnedError{Path: runtimePath}
+		}
+	}
+
+	if err := os.RemoveAll(runtimePath); err != nil {
+		return fmt.Errorf("unable to clean up runtime directory %s with error: %w", runtimePath, err)
+	}
+
+	return nil
+}
+
+// createRuntimePath creates the runtime directory holding the ownership marker. It is called before the binaries are
+// extracted, so that a partially extracted runtime directory is still recognised, and again once they are in place.
+func (ep *EmbeddedPostgres) createRuntimePath() error {
+	runtimePath := ep.config.runtimePath
+
+	if err := os.MkdirAll(runtimePath, os.ModePerm); err != nil {
+		return fmt.Errorf("unable to create runtime directory %s with error: %w", runtimePath, err)
+	}
+
+	markerPath := filepath.Join(runtimePath, ownershipMarker)
+	marker := []byte("This directory was created by embedded-postgres and is deleted each time it starts.\n")
+
+	if err := os.WriteFile(markerPath, marker, 0600); err != nil {
+		return fmt.Errorf("unable to write ownership marker %s with error: %w", markerPath, err)
+	}
+
+	ownerPath := filepath.Join(runtimePath, ownerPidFile)
+	if err := os.WriteFile(ownerPath, []byte(strconv.Itoa(os.Getpid())), 0600); err != nil {
+		return fmt.Errorf("unable to write ownership marker %s with error: %w", ownerPath, err)
+	}
+
+	return nil
+}
+
+// runtimePathOwner returns the PID of the Go process that created the runtime directory, reporting false when it is
+// not recorded.
+func runtimePathOwner(runtimePath string) (int, bool) {
+	content, err := os.ReadFile(filepath.Join(runtimePath, ownerPidFile))
+	if err != nil {
+		return 0, false
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil {
+		return 0, false
+	}
+
+	return pid, true
+}
+
+// runtimePathOwned reports whether the runtime directory can safely be deleted, which is the case when it does not
+// exist, is empty or holds the ownership marker.
+func runtimePathOwned(runtimePath string) (bool, error) {
+	if _, err := os.Stat(filepath.Join(runtimePath, ownershipMarker)); err == nil {
+		return true, nil
+	}
+
+	dir, err := os.Open(runtimePath)
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("unable to inspect runtime directory %s with error: %w", runtimePath, err)
+	}
+
+	defer dir.Close()
+
+	if _, err := dir.Readdirnames(1); err == io.EOF {
+		return true, nil
+	} else if err != nil {
+		return false, fmt.Errorf("unable to inspect runtime directory %s with error: %w", runtimePath, err)
+	}
+
+	return false, nil
+}
@@ -0,0 +1,124 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RuntimePath_RefusesToDeleteForeignDirectory(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "runtime_path_test")
+	require.NoError(t, err)
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	precious := filepath.Join(tempDir, "precious.txt")
+	require.NoError(t, os.WriteFile(precious, []byte("do not delete"), 0600))
+
+	database := NewDatabase(DefaultConfig().
+		RuntimePath(tempDir))
+	database.cacheLocator = func() (string, bool) {
+		return "", false
+	}
+	database.remoteFetchStrategy = func(ctx context.Context) error {
+		return errors.New("did not work")
+	}
+
+	err = database.Start()
+
+	assert.ErrorIs(t, err, ErrRuntimePathNotOwned)
+	assert.EqualError(t, err, "refusing to delete runtime directory "+tempDir+" as it is not empty and was not created by embedded-postgres, set ForceRuntimePathCleanup to delete it anyway")
+	assert.FileExists(t, precious)
+}
+
+func Test_RuntimePath_ForceDeletesForeignDirectory(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "runtime_path_test")
+	require.NoError(t, err)
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	runtimePath := filepath.Join(tempDir, "runtime")
+	require.NoError(t, os.MkdirAll(runtimePath, os.ModePerm))
+	require.NoError(t, os.WriteFile(filepath.Join(runtimePath, "stale.txt"), []byte("stale"), 0600))
+
+	database := NewDatabase(DefaultConfig().
+		RuntimePath(runtimePath).
+		ForceRuntimePathCleanup(true))
+	database.cacheLocator = func() (string, bool) {
+		return "", false
+	}
+	database.remoteFetchStrategy = func(ctx context.Context) error {
+		return errors.New("did not work")
+	}
+
+	err = database.Start()
+
+	assert.EqualError(t, err, "did not work")
+	assert.NoDirExists(t, runtimePath)
+}
+
+func Test_RuntimePath_CleansMarkedDirectory(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "runtime_path_test")
+	require.NoError(t, err)
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	database := NewDatabase(DefaultConfig().
+		RuntimePath(tempDir))
+
+	require.NoError(t, database.createRuntimePath())
+	assert.FileExists(t, filepath.Join(tempDir, ownershipMarker))
+
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "data"), os.ModePerm))
+
+	assert.NoError(t, database.cleanRuntimePath())
+	assert.NoDirExists(t, tempDir)
+}
+
+func Test_runtimePathOwned(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "runtime_path_test")
+	require.NoError(t, err)
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	owned, err := runtimePathOwned(filepath.Join(tempDir, "missing"))
+	require.NoError(t, err)
+	assert.True(t, owned, "missing directory")
+
+	owned, err = runtimePathOwned(tempDir)
+	require.NoError(t, err)
+	assert.True(t, owned, "empty directory")
+
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "file.txt"), []byte("content"), 0600))
+
+	owned, err = runtimePathOwned(tempDir)
+	require.NoError(t, err)
+	assert.False(t, owned, "non-empty directory without marker")
+
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, ownershipMarker), nil, 0600))
+
+	owned, err = runtimePathOwned(tempDir)
+	require.NoError(t, err)
+	assert.True(t, owned, "directory with marker")
+}
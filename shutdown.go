@@ -0,0 +1,232 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ShutdownMode selects how Postgres treats connected clients when stopping.
+// See https://www.postgresql.org/docs/current/app-pg-ctl.html
+type ShutdownMode string
+
+// Shutdown modes supported by pg_ctl.
+const (
+	// ShutdownSmart waits for all clients to disconnect before shutting down.
+	ShutdownSmart = ShutdownMode("smart")
+	// ShutdownFast rolls back open transactions and disconnects clients before shutting down, the pg_ctl default.
+	ShutdownFast = ShutdownMode("fast")
+	// ShutdownImmediate aborts all server processes without a clean shutdown, leading to recovery on the next start.
+	ShutdownImmediate = ShutdownMode("immediate")
+
+	shutdownKill = ShutdownMode("kill")
+)
+
+// OpenConnection describes a client connection that was open when Postgres was asked to stop.
+type OpenConnection struct {
+	PID             int
+	Username        string
+	Database        string
+	ApplicationName string
+	ClientAddress   string
+	State           string
+	Query           string
+}
+
+func (c OpenConnection) String() string {
+	return fmt.Sprintf("pid=%d user=%s database=%s application=%q client=%s state=%q query=%q",
+		c.PID,
+		c.Username,
+		c.Database,
+		c.ApplicationName,
+		c.ClientAddress,
+		c.State,
+		c.Query)
+}
+
+// StopTimeoutError is returned by Stop when Postgres did not shut down within the configured StopTimeout using the
+// configured ShutdownMode. By the time it is returned the server has been stopped with a more forceful shutdown mode,
+// or killed when EscalatedTo is "kill".
+type StopTimeoutError struct {
+	Timeout     time.Duration
+	Mode        ShutdownMode
+	EscalatedTo ShutdownMode
+	// Connections were open when Stop began rather than when the timeout expired, they are the most likely reason for
+	// the server not stopping in time.
+	Connections []OpenConnection
+}
+
+func (e *StopTimeoutError) Error() string {
+	connections := "none"
+
+	if len(e.Connections) > 0 {
+		descriptions := make([]string, 0, len(e.Connections))
+		for _, connection := range e.Connections {
+			descriptions = append(descriptions, connection.String())
+		}
+
+		connections = strings.Join(descriptions, ", ")
+	}
+
+	return fmt.Sprintf("postgres did not stop within %s using %s shutdown and was stopped using %s instead, open connections: %s",
+		e.Timeout,
+		e.Mode,
+		e.EscalatedTo,
+		connections)
+}
+
+func (e *StopTimeoutError) Is(target error) bool { return target == ErrStopTimeout }
+
+// shutdownEscalation lists the shutdown modes to try in order, each one more forceful than the last.
+func shutdownEscalation(mode ShutdownMode) []ShutdownMode {
+	switch mode {
+	case ShutdownSmart:
+		return []ShutdownMode{ShutdownSmart, ShutdownFast, ShutdownImmediate}
+	case ShutdownImmediate:
+		return []ShutdownMode{ShutdownImmediate}
+	default:
+		return []ShutdownMode{ShutdownFast, ShutdownImmediate}
+	}
+}
+
+func pgCtlStop(ctx context.Context, ep *EmbeddedPostgres, mode ShutdownMode, timeout time.Duration) error {
+	args := []string{"stop", "-w"}
+
+	if mode != "" {
+		args = append(args, "-m", string(mode))
+	}
+
+	if timeout > 0 {
+		args = append(args, "-t", fmt.Sprintf("%d", int(math.Ceil(timeout.Seconds()))))
+	}
+
+	args = append(args, "-D", ep.config.dataPath)
+
+	postgresBinary := filepath.Join(ep.config.binariesPath, "bin/pg_ctl")
+	postgresProcess := exec.CommandContext(ctx, postgresBinary, args...)
+	postgresProcess.Stderr = ep.syncedLogger.file
+	postgresProcess.Stdout = ep.syncedLogger.file
+	ep.runAs.apply(postgresProcess)
+
+	return postgresProcess.Run()
+}
+
+// stopPostgresOrEscalate stops Postgres using the configured shutdown mode, moving on to ever more forceful modes and
+// finally killing the process whenever the stop timeout expires. The open connections are recorded before the first
+// attempt, as Postgres refuses new connections, and so the query listing them, once it is shutting down.
+func stopPostgresOrEscalate(ctx context.Context, ep *EmbeddedPostgres) error {
+	connections, _ := openConnections(ctx, ep.config)
+
+	modes := shutdownEscalation(ep.config.shutdownMode)
+	for i, mode := range modes {
+		err := pgCtlStop(ctx, ep, mode, ep.config.stopTimeout)
+		if err == nil {
+			if i == 0 {
+				return nil
+			}
+
+			return &StopTimeoutError{
+				Timeout:     ep.config.stopTimeout,
+				Mode:        modes[0],
+				EscalatedTo: mode,
+				Connections: connections,
+			}
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		// without a pid file there is nothing to escalate against, pg_ctl failed for another reason
+		if _, exists, _ := readPostmasterPidFile(ep.config.dataPath); !exists {
+			return err
+		}
+	}
+
+	if err := killPostgres(ctx, ep); err != nil {
+		return err
+	}
+
+	return &StopTimeoutError{
+		Timeout:     ep.config.stopTimeout,
+		Mode:        modes[0],
+		EscalatedTo: shutdownKill,
+		Connections: connections,
+	}
+}
+
+func killPostgres(ctx context.Context, ep *EmbeddedPostgres) error {
+	if ep.postmaster != nil {
+		return ep.postmaster.cmd.Process.Kill()
+	}
+
+	pidFile, exists, err := readPostmasterPidFile(ep.config.dataPath)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return nil
+	}
+
+	process, err := os.FindProcess(pidFile.pid)
+	if err != nil {
+		return fmt.Errorf("unable to kill postgres process %d: %w", pidFile.pid, err)
+	}
+
+	if err := process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return fmt.Errorf("unable to kill postgres process %d: %w", pidFile.pid, err)
+	}
+
+	return waitForProcessExit(ctx, pidFile.pid, ep.config.stopTimeout)
+}
+
+func openConnections(ctx context.Context, config Config) (connections []OpenConnection, err error) {
+	timeout, cancelFunc := context.WithTimeout(ctx, config.stopTimeout)
+	defer cancelFunc()
+
+	conn, err := openDatabaseConnection(config.connectionHost(), config.port, config.username, config.password, "postgres")
+	if err != nil {
+		return nil, err
+	}
+
+	db := sql.OpenDB(conn)
+	defer func() {
+		err = connectionClose(db, err)
+	}()
+
+	// client_port is null for background processes, and -1 for clients connected through a unix socket
+	rows, err := db.QueryContext(timeout, `SELECT pid,
+		coalesce(usename, ''),
+		coalesce(datname, ''),
+		coalesce(application_name, ''),
+		coalesce(host(client_addr), ''),
+		coalesce(state, ''),
+		coalesce(query, '')
+		FROM pg_stat_activity
+		WHERE pid <> pg_backend_pid() AND client_port IS NOT NULL
+		ORDER BY pid`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var c OpenConnection
+		if err := rows.Scan(&c.PID, &c.Username, &c.Database, &c.ApplicationName, &c.ClientAddress, &c.State, &c.Query); err != nil {
+			return nil, err
+		}
+
+		connections = append(connections, c)
+	}
+
+	return connections, rows.Err()
+}
@@ -0,0 +1,80 @@
+package embeddedpostgres
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_shutdownEscalation(t *testing.T) {
+	assert.Equal(t, []ShutdownMode{ShutdownSmart, ShutdownFast, ShutdownImmediate}, shutdownEscalation(ShutdownSmart))
+	assert.Equal(t, []ShutdownMode{ShutdownFast, ShutdownImmediate}, shutdownEscalation(ShutdownFast))
+	assert.Equal(t, []ShutdownMode{ShutdownFast, ShutdownImmediate}, shutdownEscalation(""))
+	assert.Equal(t, []ShutdownMode{ShutdownImmediate}, shutdownEscalation(ShutdownImmediate))
+}
+
+func Test_StopTimeoutError(t *testing.T) {
+	err := &StopTimeoutError{
+		Timeout:     2 * time.Second,
+		Mode:        ShutdownSmart,
+		EscalatedTo: ShutdownFast,
+		Connections: []OpenConnection{{
+			PID:             123,
+			Username:        "postgres",
+			Database:        "beer",
+			ApplicationName: "psql",
+			ClientAddress:   "127.0.0.1",
+			State:           "idle in transaction",
+			Query:           "BEGIN",
+		}},
+	}
+
+	assert.EqualError(t, err, `postgres did not stop within 2s using smart shutdown and was stopped using fast instead, open connections: pid=123 user=postgres database=beer application="psql" client=127.0.0.1 state="idle in transaction" query="BEGIN"`)
+	assert.True(t, isStopTimeout(err))
+}
+
+func Test_StopTimeoutError_NoConnections(t *testing.T) {
+	err := &StopTimeoutError{
+		Timeout:     time.Second,
+		Mode:        ShutdownImmediate,
+		EscalatedTo: "kill",
+	}
+
+	assert.EqualError(t, err, "postgres did not stop within 1s using immediate shutdown and was stopped using kill instead, open connections: none")
+}
+
+func Test_StopEscalatesWhenConnectionLeaked(t *testing.T) {
+	database := NewDatabase(DefaultConfig().
+		Port(9836).
+		ShutdownMode(ShutdownSmart).
+		StopTimeout(time.Second))
+
+	if err := database.Start(); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	db, err := sql.Open("postgres", database.GetConnectionURL()+"?sslmode=disable&application_name=leaky")
+	if err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	defer db.Close()
+
+	if _, err := db.Exec("BEGIN"); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	err = database.Stop()
+
+	var stopTimeoutErr *StopTimeoutError
+	require.True(t, errors.As(err, &stopTimeoutErr))
+	assert.Equal(t, ShutdownSmart, stopTimeoutErr.Mode)
+	assert.Equal(t, ShutdownFast, stopTimeoutErr.EscalatedTo)
+	require.Len(t, stopTimeoutErr.Connections, 1)
+	assert.Equal(t, "leaky", stopTimeoutErr.Connections[0].ApplicationName)
+	assert.Equal(t, StateStopped, database.State())
+}
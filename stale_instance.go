@@ -0,0 +1,137 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// reapStaleInstance stops a Postgres process left running on the data directory by an earlier run that was never
+// stopped, so that its port and runtime directory can be reused. It refuses to touch processes that were not started
+// from the configured binaries, and those whose owning Go process is still running or unknown, such as the server of a
+// parallel test package sharing the same runtime directory.
+func reapStaleInstance(ctx context.Context, ep *EmbeddedPostgres) error {
+	pidFile, exists, err := readPostmasterPidFile(ep.config.dataPath)
+	if err != nil || !exists {
+		return err
+	}
+
+	executable, running, err := processExecutable(pidFile.pid)
+	if err != nil {
+		return err
+	}
+
+	// Postgres itself replaces a postmaster.pid file left behind by a process that is no longer running
+	if !running {
+		return nil
+	}
+
+	if !isEmbeddedPostgresExecutable(executable, ep.config.binariesPath) {
+		return &DataDirectoryLockedError{DataPath: ep.config.dataPath, PID: pidFile.pid, Executable: executable}
+	}
+
+	ownerPID, known := runtimePathOwner(ep.config.runtimePath)
+	if !known || ownerRunning(ownerPID) {
+		return &DataDirectoryLockedError{DataPath: ep.config.dataPath, PID: pidFile.pid, Executable: executable,
+			Embedded: true, OwnerPID: ownerPID}
+	}
+
+	return stopStaleInstance(ctx, ep, pidFile.pid)
+}
+
+// ownerRunning reports whether the owning Go process is running, assuming it is when that cannot be determined.
+func ownerRunning(pid int) bool {
+	if pid == os.Getpid() {
+		return true
+	}
+
+	_, running, err := processExecutable(pid)
+
+	return err != nil || running
+}
+
+func isEmbeddedPostgresExecutable(executable, binariesPath string) bool {
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(executable, "postgres.exe")
+	}
+
+	expected, err := filepath.Abs(filepath.Join(binariesPath, "bin", "postgres"))
+	if err != nil {
+		return false
+	}
+
+	if filepath.Clean(executable) == expected {
+		return true
+	}
+
+	// either path may run through a symlink, such as /tmp on macOS
+	resolvedExecutable, executableErr := filepath.EvalSymlinks(executable)
+	resolvedExpected, expectedErr := filepath.EvalSymlinks(expected)
+
+	return executableErr == nil && expectedErr == nil && resolvedExecutable == resolvedExpected
+}
+
+func stopStaleInstance(ctx context.Context, ep *EmbeddedPostgres, pid int) error {
+	postgresBinary := filepath.Join(ep.config.binariesPath, "bin/pg_ctl")
+	if _, err := os.Stat(postgresBinary); err == nil {
+		postgresProcess := exec.CommandContext(ctx, postgresBinary, "stop", "-w", "-m", "fast",
+			"-D", ep.config.dataPath)
+		postgresProcess.Stdout = ep.syncedLogger.file
+		postgresProcess.Stderr = ep.syncedLogger.file
+		ep.runAs.apply(postgresProcess)
+
+		if err := postgresProcess.Run(); err == nil {
+			return nil
+		}
+	}
+
+	// pg_ctl is unavailable or could not stop the server, fall back to signalling the process directly
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return fmt.Errorf("unable to stop stale postgres process %d: %w", pid, err)
+	}
+
+	if err := process.Signal(os.Interrupt); err != nil {
+		_ = process.Kill()
+	}
+
+	if err := waitForProcessExit(ctx, pid, ep.config.startTimeout); err == nil {
+		return nil
+	}
+
+	if err := process.Kill(); err != nil {
+		return fmt.Errorf("unable to stop stale postgres process %d: %w", pid, err)
+	}
+
+	return waitForProcessExit(ctx, pid, ep.config.startTimeout)
+}
+
+func waitForProcessExit(ctx context.Context, pid int, timeout time.Duration) error {
+	timeoutCtx, cancelFunc := context.WithTimeout(ctx, timeout)
+	defer cancelFunc()
+
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		if _, running, err := processExecutable(pid); err == nil && !running {
+			return nil
+		}
+
+		select {
+		case <-timeoutCtx.Done():
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			return errors.New("timed out waiting for stale postgres process to exit")
+		case <-ticker.C:
+		}
+	}
+}
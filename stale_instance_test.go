@@ -0,0 +1,168 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_reapStaleInstance_NoPidFile(t *testing.T) {
+	database := staleInstanceTestDatabase(t)
+
+	assert.NoError(t, reapStaleInstance(context.Background(), database))
+}
+
+func Test_reapStaleInstance_IgnoresPidOfExitedProcess(t *testing.T) {
+	database := staleInstanceTestDatabase(t)
+
+	writePostmasterPidFile(t, database.config.dataPath, exitedProcessPid(t))
+
+	assert.NoError(t, reapStaleInstance(context.Background(), database))
+}
+
+func Test_reapStaleInstance_ErrorWhenForeignProcess(t *testing.T) {
+	database := staleInstanceTestDatabase(t)
+
+	writePostmasterPidFile(t, database.config.dataPath, os.Getpid())
+
+	err := reapStaleInstance(context.Background(), database)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), fmt.Sprintf("data directory %s is locked by process %d", database.config.dataPath, os.Getpid()))
+	assert.Contains(t, err.Error(), "which was not started by embedded-postgres")
+	assert.ErrorIs(t, err, ErrDataDirectoryLocked)
+}
+
+func Test_reapStaleInstance_StopsOrphanedInstance(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a copy of sleep in place of the postgres binary")
+	}
+
+	database := staleInstanceTestDatabase(t)
+
+	sleepBinary, err := exec.LookPath("sleep")
+	require.NoError(t, err)
+
+	postgresBinary := filepath.Join(database.config.binariesPath, "bin", "postgres")
+	copyFile(t, sleepBinary, postgresBinary)
+
+	orphan := exec.Command(postgresBinary, "60")
+	require.NoError(t, orphan.Start())
+
+	exited := make(chan struct{})
+	go func() {
+		_ = orphan.Wait()
+		close(exited)
+	}()
+
+	writePostmasterPidFile(t, database.config.dataPath, orphan.Process.Pid)
+	writeOwnerPidFile(t, database.config.runtimePath, exitedProcessPid(t))
+
+	assert.NoError(t, reapStaleInstance(context.Background(), database))
+
+	select {
+	case <-exited:
+	case <-time.After(10 * time.Second):
+		_ = orphan.Process.Kill()
+		t.Fatal("orphaned process was not stopped")
+	}
+}
+
+func Test_reapStaleInstance_ErrorWhenOwnerStillRunning(t *testing.T) {
+	for name, ownerPid := range map[string]int{"running owner": os.Getpid(), "unknown owner": 0} {
+		t.Run(name, func(t *testing.T) {
+			database, orphan := startSleepingPostgres(t)
+
+			if ownerPid != 0 {
+				writeOwnerPidFile(t, database.config.runtimePath, ownerPid)
+			}
+
+			err := reapStaleInstance(context.Background(), database)
+
+			assert.ErrorIs(t, err, ErrDataDirectoryLocked)
+
+			var lockedErr *DataDirectoryLockedError
+			require.ErrorAs(t, err, &lockedErr)
+			assert.True(t, lockedErr.Embedded)
+			assert.Equal(t, ownerPid, lockedErr.OwnerPID)
+
+			_, running, err := processExecutable(orphan.Process.Pid)
+			require.NoError(t, err)
+			assert.True(t, running, "the server of a live owner must not be stopped")
+		})
+	}
+}
+
+// startSleepingPostgres runs a copy of sleep as the postgres binary of the configured binaries, as if it was a server
+// left running on the data directory.
+func startSleepingPostgres(t *testing.T) (*EmbeddedPostgres, *exec.Cmd) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a copy of sleep in place of the postgres binary")
+	}
+
+	database := staleInstanceTestDatabase(t)
+
+	sleepBinary, err := exec.LookPath("sleep")
+	require.NoError(t, err)
+
+	postgresBinary := filepath.Join(database.config.binariesPath, "bin", "postgres")
+	copyFile(t, sleepBinary, postgresBinary)
+
+	orphan := exec.Command(postgresBinary, "60")
+	require.NoError(t, orphan.Start())
+
+	t.Cleanup(func() {
+		_ = orphan.Process.Kill()
+		_ = orphan.Wait()
+	})
+
+	writePostmasterPidFile(t, database.config.dataPath, orphan.Process.Pid)
+
+	return database, orphan
+}
+
+func staleInstanceTestDatabase(t *testing.T) *EmbeddedPostgres {
+	database, _ := fixtureDatabase(t, DefaultConfig().StartTimeout(5*time.Second), nil)
+
+	return database
+}
+
+func writePostmasterPidFile(t *testing.T, dataPath string, pid int) {
+	content := fmt.Sprintf("%d\n%s\n%d\n5432\n/tmp\nlocalhost\n", pid, dataPath, time.Now().Unix())
+	require.NoError(t, os.WriteFile(filepath.Join(dataPath, "postmaster.pid"), []byte(content), 0600))
+}
+
+func writeOwnerPidFile(t *testing.T, runtimePath string, pid int) {
+	require.NoError(t, os.MkdirAll(runtimePath, os.ModePerm))
+	require.NoError(t, os.WriteFile(filepath.Join(runtimePath, ownerPidFile), []byte(fmt.Sprintf("%d", pid)), 0600))
+}
+
+func exitedProcessPid(t *testing.T) int {
+	process := exec.Command(os.Args[0], "-test.run=^$")
+	require.NoError(t, process.Run())
+
+	return process.Process.Pid
+}
+
+func copyFile(t *testing.T, source, destination string) {
+	in, err := os.Open(source)
+	require.NoError(t, err)
+
+	defer in.Close()
+
+	out, err := os.OpenFile(destination, os.O_CREATE|os.O_WRONLY, 0755)
+	require.NoError(t, err)
+
+	_, err = io.Copy(out, in)
+	require.NoError(t, err)
+	require.NoError(t, out.Close())
+}
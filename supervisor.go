@@ -0,0 +1,200 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// postmaster supervises a Postgres process that was started directly as a child process rather than through pg_ctl.
+type postmaster struct {
+	cmd      *exec.Cmd
+	done     chan struct{}
+	stopping int32
+	err      error
+}
+
+func postgresArgs(dataPath string, port uint32, parameters map[string]string) []string {
+	args := []string{"-D", dataPath, "-p", fmt.Sprintf("%d", port)}
+	for k, v := range parameters {
+		args = append(args, "-c", fmt.Sprintf("%s=%s", k, v))
+	}
+
+	return args
+}
+
+func startSupervisedPostgres(ctx context.Context, ep *EmbeddedPostgres) (*postmaster, error) {
+	postgresBinary := filepath.Join(ep.config.binariesPath, "bin/postgres")
+	postgresProcess := exec.Command(postgresBinary, postgresArgs(ep.config.dataPath, ep.config.port, ep.config.serverParameters())...)
+	postgresProcess.Stdout = ep.syncedLogger.file
+	postgresProcess.Stderr = ep.syncedLogger.file
+	ep.runAs.apply(postgresProcess)
+
+	if ep.config.stopOnProcessExit {
+		if err := bindLifetimeToParent(postgresProcess); err != nil {
+			return nil, err
+		}
+	}
+
+	p := &postmaster{
+		cmd:  postgresProcess,
+		done: make(chan struct{}),
+	}
+
+	if err := p.start(ep.config.stopOnProcessExit); err != nil {
+		return nil, &StartError{Command: postgresProcess.String(), Err: err}
+	}
+
+	if err := p.waitUntilReady(ctx, ep.config, ep.syncedLogger); err != nil {
+		p.kill()
+
+		if errors.Is(err, errPostmasterExited) {
+			_ = ep.syncedLogger.flush()
+			logContent, _ := readLogsOrTimeout(ep.syncedLogger.file)
+
+			return nil, &StartError{Command: postgresProcess.String(), Err: p.err, Log: string(logContent)}
+		}
+
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// start runs the process and waits for it in the background, closing done once it has exited.
+func (p *postmaster) start(lockThread bool) error {
+	started := make(chan error, 1)
+
+	go func() {
+		if lockThread {
+			// the parent death signal is sent when the thread that started the process exits, rather than when the
+			// whole Go process exits, so the thread is kept alive for as long as the process runs and discarded after.
+			runtime.LockOSThread()
+		}
+
+		if err := p.cmd.Start(); err != nil {
+			started <- err
+			return
+		}
+
+		started <- nil
+
+		p.err = p.cmd.Wait()
+		close(p.done)
+	}()
+
+	return <-started
+}
+
+var errPostmasterExited = errors.New("postgres exited")
+
+// waitUntilReady polls the server until it accepts connections, the process exits or the start timeout passes.
+func (p *postmaster) waitUntilReady(ctx context.Context, config Config, logger *syncedLogger) error {
+	timeout, cancelFunc := context.WithTimeout(ctx, config.startTimeout)
+	defer cancelFunc()
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		if err := healthCheckDatabase(timeout, config.connectionHost(), config.port, "postgres", config.username, config.password); err == nil {
+			return nil
+		}
+
+		select {
+		case <-p.done:
+			return errPostmasterExited
+		case <-timeout.Done():
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			return newStartTimeoutError(config, logger)
+		case <-ticker.C:
+		}
+	}
+}
+
+// expectExit marks the coming exit of the process as requested, so that Wait does not report it as a failure.
+func (p *postmaster) expectExit() {
+	atomic.StoreInt32(&p.stopping, 1)
+}
+
+func (p *postmaster) exitExpected() bool {
+	return atomic.LoadInt32(&p.stopping) == 1
+}
+
+func (p *postmaster) kill() {
+	p.expectExit()
+	_ = p.cmd.Process.Kill()
+	<-p.done
+}
+
+func (p *postmaster) exited() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Done returns a channel that is closed once the Postgres process started by the last call to Start has exited.
+// This includes both an exit requested by Stop and the process terminating unexpectedly.
+// When not running Supervised the channel is only closed once Stop has completed.
+// Done returns nil if Start has never succeeded.
+func (ep *EmbeddedPostgres) Done() <-chan struct{} {
+	ep.stateMu.Lock()
+	defer ep.stateMu.Unlock()
+
+	return ep.done
+}
+
+// Wait blocks until the Postgres process started by the last call to Start has exited. It returns nil when the process
+// was stopped by Stop, or an error including the server log when the process exited unexpectedly.
+func (ep *EmbeddedPostgres) Wait() error {
+	ep.stateMu.Lock()
+	done, postmaster, logger := ep.done, ep.postmaster, ep.syncedLogger
+	ep.stateMu.Unlock()
+
+	if done == nil {
+		return ErrServerNotStarted
+	}
+
+	<-done
+
+	if postmaster == nil {
+		return nil
+	}
+
+	return postmaster.exitError(logger)
+}
+
+// ExitCode returns the exit code of the Postgres process started by the last call to Start when running Supervised.
+// It returns -1 when the process is still running or has not been supervised.
+func (ep *EmbeddedPostgres) ExitCode() int {
+	ep.stateMu.Lock()
+	postmaster := ep.postmaster
+	ep.stateMu.Unlock()
+
+	if postmaster == nil || !postmaster.exited() {
+		return -1
+	}
+
+	return postmaster.cmd.ProcessState.ExitCode()
+}
+
+func (p *postmaster) exitError(logger *syncedLogger) error {
+	if p.exitExpected() {
+		return nil
+	}
+
+	logContent, _ := readLogsOrTimeout(logger.file)
+
+	return &UnexpectedExitError{ExitCode: p.cmd.ProcessState.ExitCode(), Err: p.err, Log: string(logContent)}
+}
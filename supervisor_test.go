@@ -0,0 +1,125 @@
+package embeddedpostgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_postgresArgs(t *testing.T) {
+	args := postgresArgs("/data", 5433, map[string]string{"max_connections": "101"})
+
+	assert.Equal(t, []string{"-D", "/data", "-p", "5433", "-c", "max_connections=101"}, args)
+}
+
+func Test_Wait_ErrorWhenNotStarted(t *testing.T) {
+	database := NewDatabase()
+
+	assert.Nil(t, database.Done())
+	assert.ErrorIs(t, database.Wait(), ErrServerNotStarted)
+	assert.Equal(t, -1, database.ExitCode())
+}
+
+func Test_startSupervisedPostgres_ErrorWhenProcessExits(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script in place of the postgres binary")
+	}
+
+	tempDir, err := os.MkdirTemp("", "supervisor_test")
+	require.NoError(t, err)
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "bin"), os.ModePerm))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "bin", "postgres"), []byte("#!/bin/sh\necho 'FATAL: it crashed' >&2\nexit 3\n"), 0755))
+
+	logger, err := newSyncedLogger("", nil)
+	require.NoError(t, err)
+
+	database := NewDatabase(DefaultConfig().
+		BinariesPath(tempDir).
+		DataPath(filepath.Join(tempDir, "data")).
+		Port(9833).
+		StartTimeout(10 * time.Second))
+	database.syncedLogger = logger
+
+	_, err = startSupervisedPostgres(context.Background(), database)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "could not start postgres using "+filepath.Join(tempDir, "bin", "postgres"))
+	assert.Contains(t, err.Error(), "FATAL: it crashed")
+	assert.ErrorIs(t, err, ErrStartFailed)
+}
+
+func Test_Supervised_ReportsUnexpectedExit(t *testing.T) {
+	database := NewDatabase(DefaultConfig().
+		Port(9834).
+		Supervised(true))
+
+	if err := database.Start(); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	if err := database.postmaster.cmd.Process.Kill(); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	select {
+	case <-database.Done():
+	case <-time.After(10 * time.Second):
+		shutdownDBAndFail(t, errors.New("postgres did not exit"), database)
+	}
+
+	err := database.Wait()
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "postgres exited unexpectedly")
+	assert.NotEqual(t, 0, database.ExitCode())
+
+	var exitErr *UnexpectedExitError
+	require.True(t, errors.As(err, &exitErr))
+	assert.Equal(t, database.ExitCode(), exitErr.ExitCode)
+	assert.EqualError(t, database.Stop(), err.Error())
+}
+
+func Test_Supervised_StopIsNotReportedAsFailure(t *testing.T) {
+	database := NewDatabase(DefaultConfig().
+		Port(9835).
+		Supervised(true))
+
+	if err := database.Start(); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	db, err := sql.Open("postgres", database.GetConnectionURL()+"?sslmode=disable")
+	if err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	if err = db.Ping(); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	if err := db.Close(); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	if err := database.Stop(); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	assert.NoError(t, database.Wait())
+	assert.Equal(t, 0, database.ExitCode())
+}
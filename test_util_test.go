@@ -1,10 +1,20 @@
 package embeddedpostgres
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
 	"os"
+	"path/filepath"
+	"runtime"
 	"testing"
 
+	"github.com/stretchr/testify/require"
 	"go.uber.org/goleak"
 )
 
@@ -38,8 +48,162 @@ func writeFileWithBase64Content(filename, base64Content string) (string, func())
 	}
 }
 
+// fixtureDatabase returns a database for config using the runtime, binaries and data directories of a new temporary
+// directory, which is returned as well and removed once the test has finished. Each of scripts is written to the bin
+// directory of the binaries, standing in for the binary of the same name, and the log is written to the temporary
+// directory.
+func fixtureDatabase(t *testing.T, config Config, scripts map[string]string) (*EmbeddedPostgres, string) {
+	if len(scripts) > 0 && runtime.GOOS == "windows" {
+		t.Skip("uses shell scripts in place of the binaries")
+	}
+
+	tempDir, err := os.MkdirTemp("", "embedded_postgres_test")
+	require.NoError(t, err)
+
+	binariesPath := filepath.Join(tempDir, "binaries")
+	dataPath := filepath.Join(tempDir, "data")
+
+	require.NoError(t, os.MkdirAll(filepath.Join(binariesPath, "bin"), os.ModePerm))
+	require.NoError(t, os.MkdirAll(dataPath, os.ModePerm))
+
+	for name, script := range scripts {
+		require.NoError(t, os.WriteFile(filepath.Join(binariesPath, "bin", name), []byte(script), 0755))
+	}
+
+	logger, err := newSyncedLogger(tempDir, nil)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		if err := logger.file.Close(); err != nil {
+			panic(err)
+		}
+
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	})
+
+	database := NewDatabase(config.
+		RuntimePath(filepath.Join(tempDir, "runtime")).
+		BinariesPath(binariesPath).
+		DataPath(dataPath))
+	database.syncedLogger = logger
+
+	return database, tempDir
+}
+
+// exitScript is a script that only exits with exitCode, for use in place of a binary with fixtureDatabase.
+func exitScript(exitCode string) string {
+	return "#!/bin/sh\nexit " + exitCode + "\n"
+}
+
+// fakeBinariesDatabase returns a database using a pg_ctl script that exits with exitCode in place of the real binaries,
+// and an initdb that records being called and only writes the PG_VERSION file.
+func fakeBinariesDatabase(t *testing.T, config Config, exitCode string, calls *[]string) *EmbeddedPostgres {
+	database, _ := fixtureDatabase(t, config.Port(0), map[string]string{"pg_ctl": exitScript(exitCode)})
+	database.initDatabase = func(ctx context.Context, binaryExtractLocation, runtimePath, pgDataDir, username, password, locale string, encoding string, logger *os.File, runAs *osUser, options initDBOptions) error {
+		*calls = append(*calls, "initdb")
+
+		if err := os.MkdirAll(pgDataDir, os.ModePerm); err != nil {
+			return err
+		}
+
+		return os.WriteFile(filepath.Join(pgDataDir, "PG_VERSION"), []byte(majorVersion(config.version)+"\n"), 0600)
+	}
+
+	return database
+}
+
+// fakePostgresServer accepts connections on the Unix socket Postgres would create in socketDirectory for port, with any
+// credentials, and answers every query with an empty result. It lets Start and Restart pass their health checks when
+// the binaries are replaced by scripts.
+func fakePostgresServer(t *testing.T, socketDirectory string, port uint32) {
+	listener, err := net.Listen("unix", filepath.Join(socketDirectory, fmt.Sprintf(".s.PGSQL.%d", port)))
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go serveFakePostgres(conn)
+		}
+	}()
+}
+
+func serveFakePostgres(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+
+	// the startup message is the only one without a type
+	if _, err := readFakePostgresMessage(reader, false); err != nil {
+		return
+	}
+
+	if _, err := conn.Write(append(fakePostgresMessage('R', 0, 0, 0, 0), fakePostgresMessage('Z', 'I')...)); err != nil {
+		return
+	}
+
+	for {
+		messageType, err := readFakePostgresMessage(reader, true)
+		if err != nil || messageType == 'X' {
+			return
+		}
+
+		if messageType != 'Q' {
+			continue
+		}
+
+		var result bytes.Buffer
+
+		// a single text column named "name" without any rows
+		result.Write(fakePostgresMessage('T', 0, 1, 'n', 'a', 'm', 'e', 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 25, 0xff, 0xff, 0xff,
+			0xff, 0xff, 0xff, 0, 0))
+		result.Write(fakePostgresMessage('C', append([]byte("SELECT 0"), 0)...))
+		result.Write(fakePostgresMessage('Z', 'I'))
+
+		if _, err := conn.Write(result.Bytes()); err != nil {
+			return
+		}
+	}
+}
+
+func readFakePostgresMessage(reader *bufio.Reader, typed bool) (byte, error) {
+	var messageType byte
+
+	if typed {
+		var err error
+		if messageType, err = reader.ReadByte(); err != nil {
+			return 0, err
+		}
+	}
+
+	var length int32
+	if err := binary.Read(reader, binary.BigEndian, &length); err != nil {
+		return 0, err
+	}
+
+	_, err := io.CopyN(io.Discard, reader, int64(length-4))
+
+	return messageType, err
+}
+
+func fakePostgresMessage(messageType byte, body ...byte) []byte {
+	message := []byte{messageType, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(message[1:], uint32(len(body)+4))
+
+	return append(message, body...)
+}
+
 func shutdownDBAndFail(t *testing.T, err error, db *EmbeddedPostgres) {
-	if db.started {
+	if db.State() == StateRunning {
 		if stopErr := db.Stop(); stopErr != nil {
 			t.Errorf("Failed to shutdown server with error %s", stopErr)
 		}
@@ -54,6 +218,11 @@ func testVersionStrategy() VersionStrategy {
 	}
 }
 
+// testJarURL is the binaries URL the default remote fetch strategy downloads from host using testVersionStrategy.
+func testJarURL(host string) string {
+	return host + "/io/zonky/test/postgres/embedded-postgres-binaries-darwin-amd64/1.2.3/embedded-postgres-binaries-darwin-amd64-1.2.3.jar"
+}
+
 func testCacheLocator() CacheLocator {
 	return func() (s string, b bool) {
 		return "", false
@@ -0,0 +1,69 @@
+package embeddedpostgres
+
+import (
+	"fmt"
+	"os"
+)
+
+// prepareUnixSocketDirectory creates the directory Postgres places its Unix socket in when configured to listen on
+// one. Without a configured directory a private one is created in the temporary directory, which keeps the socket path
+// short, and is removed again by removePrivateSocketDirectory.
+func (ep *EmbeddedPostgres) prepareUnixSocketDirectory() error {
+	if !ep.config.unixSocket {
+		if ep.config.disableTCP {
+			return ErrTCPWithoutUnixSocket
+		}
+
+		return nil
+	}
+
+	if socketDirectory := ep.config.unixSocketDirectory; socketDirectory != "" {
+		if _, err := os.Stat(socketDirectory); !os.IsNotExist(err) {
+			return nil
+		}
+
+		if err := os.MkdirAll(socketDirectory, 0700); err != nil {
+			return fmt.Errorf("unable to create unix socket directory %s with error: %w", socketDirectory, err)
+		}
+
+		// only a directory created here is handed over to the OSUser, an existing one may be shared such as /tmp
+		return ep.runAs.chownAll(socketDirectory)
+	}
+
+	socketDirectory, err := os.MkdirTemp("", "embedded-postgres-")
+	if err != nil {
+		return fmt.Errorf("unable to create unix socket directory with error: %w", err)
+	}
+
+	if err := ep.runAs.chownAll(socketDirectory); err != nil {
+		_ = os.RemoveAll(socketDirectory)
+		return err
+	}
+
+	ep.stateMu.Lock()
+	ep.config.unixSocketDirectory = socketDirectory
+	ep.privateSocketDirectory = true
+	ep.stateMu.Unlock()
+
+	return nil
+}
+
+// removePrivateSocketDirectory removes the socket directory created by prepareUnixSocketDirectory, so that the next
+// start creates a new one.
+func (ep *EmbeddedPostgres) removePrivateSocketDirectory() error {
+	if !ep.privateSocketDirectory {
+		return nil
+	}
+
+	ep.stateMu.Lock()
+	socketDirectory := ep.config.unixSocketDirectory
+	ep.config.unixSocketDirectory = ""
+	ep.privateSocketDirectory = false
+	ep.stateMu.Unlock()
+
+	if err := os.RemoveAll(socketDirectory); err != nil {
+		return fmt.Errorf("unable to remove unix socket directory %s with error: %w", socketDirectory, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,147 @@
+package embeddedpostgres
+
+import (
+	"database/sql"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GetConnectionURL_UnixSocket(t *testing.T) {
+	config := DefaultConfig().
+		Database("mydb").
+		Username("myuser").
+		Password("mypass").
+		UnixSocketDirectory("/tmp/pg sockets")
+
+	assert.Equal(t, "postgresql://myuser:mypass@:5432/mydb?host=%2Ftmp%2Fpg+sockets", config.GetConnectionURL())
+	assert.Equal(t, "/tmp/pg sockets", config.connectionHost())
+}
+
+func Test_serverParameters(t *testing.T) {
+	startParameters := map[string]string{"max_connections": "101"}
+
+	assert.Equal(t, startParameters, DefaultConfig().StartParameters(startParameters).serverParameters())
+
+	parameters := DefaultConfig().
+		StartParameters(startParameters).
+		UnixSocketDirectory("/tmp/pg").
+		TCP(false).
+		serverParameters()
+
+	assert.Equal(t, map[string]string{
+		"max_connections":         "101",
+		"unix_socket_directories": "/tmp/pg",
+		"listen_addresses":        "",
+	}, parameters)
+	assert.Len(t, startParameters, 1)
+}
+
+func Test_ErrorWhenTCPDisabledWithoutUnixSocket(t *testing.T) {
+	database := NewDatabase(DefaultConfig().TCP(false))
+
+	err := database.Start()
+
+	assert.ErrorIs(t, err, ErrTCPWithoutUnixSocket)
+	assert.Equal(t, StateFailed, database.State())
+}
+
+func Test_reservePort_IgnoresTCPListenerWhenTCPDisabled(t *testing.T) {
+	listener, err := net.Listen("tcp", "localhost:9882")
+	require.NoError(t, err)
+
+	defer func() {
+		if err := listener.Close(); err != nil {
+			panic(err)
+		}
+	}()
+
+	reservation, err := reservePort(DefaultConfig().Port(9882).UnixSocket(true).TCP(false))
+	require.NoError(t, err)
+
+	reservation.release()
+}
+
+func Test_prepareUnixSocketDirectory_CreatesAndRemovesPrivateDirectory(t *testing.T) {
+	database := NewDatabase(DefaultConfig().UnixSocket(true))
+
+	require.NoError(t, database.prepareUnixSocketDirectory())
+
+	socketDirectory := database.config.unixSocketDirectory
+	info, err := os.Stat(socketDirectory)
+	require.NoError(t, err)
+	assert.True(t, info.IsDir())
+	assert.Contains(t, database.GetConnectionURL(), "?host=")
+
+	require.NoError(t, database.removePrivateSocketDirectory())
+
+	_, err = os.Stat(socketDirectory)
+	assert.True(t, os.IsNotExist(err))
+	assert.Equal(t, "", database.config.unixSocketDirectory)
+}
+
+func Test_prepareUnixSocketDirectory_KeepsConfiguredDirectory(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "unix_socket_test")
+	require.NoError(t, err)
+
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			panic(err)
+		}
+	}()
+
+	database := NewDatabase(DefaultConfig().UnixSocketDirectory(tempDir))
+
+	require.NoError(t, database.prepareUnixSocketDirectory())
+	require.NoError(t, database.removePrivateSocketDirectory())
+
+	_, err = os.Stat(tempDir)
+	assert.NoError(t, err)
+	assert.Equal(t, tempDir, database.config.unixSocketDirectory)
+}
+
+func Test_UnixSocket_WithoutTCP(t *testing.T) {
+	database := NewDatabase(DefaultConfig().
+		Port(9838).
+		Database("socket").
+		UnixSocket(true).
+		TCP(false))
+	if err := database.Start(); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	socketDirectory := database.config.unixSocketDirectory
+
+	db, err := sql.Open("postgres", database.GetConnectionURL())
+	if err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	if err := db.Ping(); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	if err := db.Close(); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	// nothing listens on TCP, so the port is free
+	listener, err := net.Listen("tcp", "localhost:9838")
+	if err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	if err := listener.Close(); err != nil {
+		shutdownDBAndFail(t, err, database)
+	}
+
+	if err := database.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = os.Stat(socketDirectory)
+	assert.True(t, os.IsNotExist(err))
+}